@@ -0,0 +1,363 @@
+package xdgicons
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Reason a directory is part of the base directory list
+type BaseDirSource int
+
+const (
+	// $HOME/.icons
+	SourceHomeIcons BaseDirSource = iota
+
+	// An entry of $XDG_DATA_DIRS, with "icons" appended
+	SourceXDGDataDirs
+
+	// /usr/share/pixmaps
+	SourcePixmaps
+
+	// [LookupConfig.BaseDirs] or [LookupConfig.AdditionalBaseDirs]
+	SourceCustom
+
+	// $XDG_DATA_HOME (or $HOME/.local/share), with "icons" appended
+	SourceXDGDataHome
+
+	// Icons of the host system and of installed Flatpak
+	// applications, when running inside a Flatpak sandbox
+	SourceFlatpakHost
+
+	// Icons exported by installed snaps, and the ones of the
+	// host system when running inside a snap
+	SourceSnap
+)
+
+func (s BaseDirSource) String() string {
+	switch s {
+	case SourceHomeIcons:
+		return "HOME/.icons"
+	case SourceXDGDataDirs:
+		return "XDG_DATA_DIRS"
+	case SourcePixmaps:
+		return "pixmaps"
+	case SourceCustom:
+		return "custom"
+	case SourceXDGDataHome:
+		return "XDG_DATA_HOME"
+	case SourceFlatpakHost:
+		return "flatpak"
+	case SourceSnap:
+		return "snap"
+	}
+	return "unknown"
+}
+
+// Order in which base directories are searched, which decides the
+// winner when a theme is installed in several of them, e.g. both in
+// the home directory and system-wide.
+//
+// For every theme subdirectory, all base directories are searched
+// in this order before moving on to the next subdirectory, both for
+// exact and closest size matches.
+type BaseDirPriority int
+
+const (
+	// Directories in the home directory first, so users
+	// can override icons of system-wide themes
+	UserFirst BaseDirPriority = iota
+
+	// System-wide directories first, ignoring user
+	// modifications of installed themes
+	SystemFirst
+)
+
+// reports whether s is a directory of the user, rather than system-wide
+func (s BaseDirSource) isUser() bool {
+	return s == SourceHomeIcons || s == SourceXDGDataHome
+}
+
+// A searched base directory and why it is searched
+type BaseDirInfo struct {
+	// Full path of the directory
+	Path string
+
+	// Why the directory is searched
+	Source BaseDirSource
+
+	// Position of the entry in $XDG_DATA_DIRS, if Source is
+	// SourceXDGDataDirs, or in the configured list if SourceCustom
+	Index int
+
+	// Whether the directory exists
+	Exists bool
+
+	// Whether the directory could be listed
+	Readable bool
+}
+
+// Reports every base directory, in search order, together
+// with where it came from and whether it is usable.
+func BaseDirs() []BaseDirInfo {
+	baseDirs := listBaseDirs()
+	for i := range baseDirs {
+		baseDirs[i].stat()
+	}
+	return baseDirs
+}
+
+func (info *BaseDirInfo) stat() {
+	stat, err := os.Stat(info.Path)
+	info.Exists = err == nil && stat.IsDir()
+	if !info.Exists {
+		return
+	}
+
+	f, err := os.Open(info.Path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	_, err = f.Readdirnames(1)
+	info.Readable = err == nil || errors.Is(err, io.EOF)
+}
+
+// Reports the base directories of this lookup, in search order,
+// together with where they came from and whether they are usable.
+//
+// The directories are taken from the environment at construction
+// time, see [IconLookup.ReloadEnvironment].
+func (il *IconLookup) BaseDirs() []BaseDirInfo {
+	il.mu.RLock()
+	baseDirs := slices.Clone(il.baseDirInfos)
+	il.mu.RUnlock()
+
+	for i := range baseDirs {
+		baseDirs[i].stat()
+	}
+	return baseDirs
+}
+
+// Reads HOME, XDG_DATA_HOME and XDG_DATA_DIRS again and updates the base directories.
+//
+// Directories that are no longer listed are dropped from the
+// cache and new ones are indexed.
+func (il *IconLookup) ReloadEnvironment() {
+	infos := il.listBaseDirs()
+
+	il.mu.Lock()
+	oldDirs := il.baseDirs
+	il.setBaseDirs(infos)
+
+	var added []string
+	for _, directory := range il.baseDirs {
+		if !slices.Contains(oldDirs, directory) {
+			added = append(added, directory)
+		}
+	}
+	for _, directory := range oldDirs {
+		if !slices.Contains(il.baseDirs, directory) {
+			delete(il.dirCache, directory)
+			delete(il.evictedDirs, directory)
+			delete(il.invalidatedDirs, directory)
+			delete(il.missingDirs, directory)
+		}
+	}
+
+	il.clearThemeInfoCache()
+	il.missingThemes = make(map[string]bool)
+	dw := il.watcher
+	il.mu.Unlock()
+
+	il.cacheBaseDirectories(context.Background(), added)
+
+	if dw != nil {
+		for _, directory := range added {
+			il.watchBaseDir(dw, directory)
+		}
+	}
+}
+
+// returns the base directory paths snapshotted from the environment.
+//
+// The slice is replaced, never modified, so it can be used without the lock.
+func (il *IconLookup) getBaseDirs() []string {
+	il.mu.RLock()
+	defer il.mu.RUnlock()
+	return il.baseDirs
+}
+
+// must be called with il.mu held, or during construction
+func (il *IconLookup) setBaseDirs(infos []BaseDirInfo) {
+	if il.baseDirPriority == SystemFirst {
+		// pixmaps stays last, since it only holds legacy icons
+		slices.SortStableFunc(infos, func(a, b BaseDirInfo) int {
+			return baseDirRank(a) - baseDirRank(b)
+		})
+	}
+
+	infos = dedupeBaseDirs(infos)
+
+	il.baseDirInfos = infos
+	il.baseDirs = make([]string, 0, len(infos))
+	for _, info := range infos {
+		il.baseDirs = append(il.baseDirs, info.Path)
+	}
+}
+
+// position of info in the SystemFirst order
+func baseDirRank(info BaseDirInfo) int {
+	switch {
+	case info.Source == SourcePixmaps:
+		return 2
+	case info.Source.isUser():
+		return 1
+	}
+	return 0
+}
+
+// Lists the base directories of this lookup: the configured ones, or
+// the ones from the environment with the additional ones added before
+// pixmaps. Index is the position in the configured list for custom ones.
+func (il *IconLookup) listBaseDirs() []BaseDirInfo {
+	if il.customBaseDirs != nil {
+		return customBaseDirs(il.customBaseDirs)
+	}
+
+	baseDirs := listBaseDirs()
+	pixmaps := slices.IndexFunc(baseDirs, func(info BaseDirInfo) bool {
+		return info.Source == SourcePixmaps
+	})
+	if pixmaps < 0 {
+		pixmaps = len(baseDirs)
+	}
+	return slices.Insert(baseDirs, pixmaps, customBaseDirs(il.additionalBaseDirs)...)
+}
+
+func customBaseDirs(dirs []string) []BaseDirInfo {
+	baseDirs := make([]BaseDirInfo, 0, len(dirs))
+	for i, dir := range dirs {
+		baseDirs = append(baseDirs, BaseDirInfo{
+			Path:   dir,
+			Source: SourceCustom,
+			Index:  i,
+		})
+	}
+	return baseDirs
+}
+
+// returns a cleaned copy of paths, keeping nil as nil
+func cleanPaths(paths []string) []string {
+	if paths == nil {
+		return nil
+	}
+	cleaned := make([]string, len(paths))
+	for i, p := range paths {
+		cleaned[i] = path.Clean(p)
+	}
+	return cleaned
+}
+
+// Lists the base directories from the environment. The filesystem is
+// only touched to find out whether this runs in a Flatpak sandbox and
+// whether snapd is installed.
+func listBaseDirs() (baseDirs []BaseDirInfo) {
+	homeDir := os.Getenv("HOME")
+	pixmapDir := "/usr/share/pixmaps"
+
+	// relative paths are invalid per the basedir spec
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if !path.IsAbs(dataHome) && homeDir != "" {
+		dataHome = path.Join(homeDir, ".local", "share")
+	}
+
+	dataDirs := strings.Split(os.Getenv("XDG_DATA_DIRS"), ":")
+	if os.Getenv("XDG_DATA_DIRS") == "" {
+		dataDirs = []string{"/usr/local/share", "/usr/share"}
+	}
+
+	if homeDir != "" {
+		baseDirs = append(baseDirs, BaseDirInfo{
+			Path:   path.Join(homeDir, ".icons"),
+			Source: SourceHomeIcons,
+		})
+	}
+
+	if path.IsAbs(dataHome) {
+		baseDirs = append(baseDirs, BaseDirInfo{
+			Path:   path.Join(dataHome, "icons"),
+			Source: SourceXDGDataHome,
+		})
+	}
+
+	for i, dataDir := range dataDirs {
+		if !path.IsAbs(dataDir) {
+			continue
+		}
+		baseDirs = append(baseDirs, BaseDirInfo{
+			Path:   path.Join(dataDir, "icons"),
+			Source: SourceXDGDataDirs,
+			Index:  i,
+		})
+	}
+
+	// the sandbox only has the icons of its runtime and the
+	// application, while status icons of the host name its themes
+	if inFlatpak() {
+		flatpakDirs := []string{"/var/lib/flatpak/exports/share/icons", "/run/host/usr/share/icons"}
+		if homeDir != "" {
+			flatpakDirs = slices.Insert(flatpakDirs, 0, path.Join(homeDir, ".local/share/flatpak/exports/share/icons"))
+		}
+		for _, dir := range flatpakDirs {
+			baseDirs = append(baseDirs, BaseDirInfo{
+				Path:   dir,
+				Source: SourceFlatpakHost,
+			})
+		}
+	}
+
+	// snapd exports the icons of snaps there, which is only in
+	// XDG_DATA_DIRS of sessions started after installing it
+	if inSnap() || snapdInstalled() {
+		snapDirs := []string{"/var/lib/snapd/desktop/icons"}
+		if inSnap() {
+			snapDirs = append(snapDirs, "/var/lib/snapd/hostfs/usr/share/icons")
+		}
+		for _, dir := range snapDirs {
+			baseDirs = append(baseDirs, BaseDirInfo{
+				Path:   dir,
+				Source: SourceSnap,
+			})
+		}
+	}
+
+	baseDirs = append(baseDirs, BaseDirInfo{
+		Path:   pixmapDir,
+		Source: SourcePixmaps,
+	})
+	return baseDirs
+}
+
+// reports whether this runs inside a Flatpak sandbox,
+// which always has /.flatpak-info
+func inFlatpak() bool {
+	_, err := os.Stat("/.flatpak-info")
+	return err == nil
+}
+
+// reports whether this runs inside a snap, which snapd tells by SNAP
+func inSnap() bool {
+	return os.Getenv("SNAP") != ""
+}
+
+// reports whether snapd is installed on this system
+func snapdInstalled() bool {
+	stat, err := os.Stat("/var/lib/snapd/desktop")
+	return err == nil && stat.IsDir()
+}
@@ -0,0 +1,194 @@
+package xdgicons
+
+import (
+	"context"
+	"path"
+	"slices"
+)
+
+// Resolves every name in iconNames, walking the theme chain once for the
+// whole set instead of once per name. Names that could not be found are
+// left out of the returned map.
+func (il *IconLookup) FindIcons(iconNames []string, size int, scale int) map[string]Icon {
+	ctx := context.Background()
+	opts := il.lookupOptions(LookupOptions{})
+	found := make(map[string]Icon, len(iconNames))
+
+	// overridden and symbolic names need their own search order
+	var pending []string
+	seen := make(map[string]bool, len(iconNames))
+	for _, iconName := range iconNames {
+		if seen[iconName] {
+			continue
+		}
+		seen[iconName] = true
+
+		_, resolvedName, ok := il.applyOverride(iconName, opts.Extensions)
+		if ok || len(il.nameVariants(resolvedName)) > 1 {
+			if icon, err := il.findIcon(ctx, iconName, size, scale, opts); err == nil {
+				found[iconName] = icon
+			}
+			continue
+		}
+		pending = append(pending, iconName)
+	}
+
+	theme, fallbackTheme := il.Theme(), il.FallbackTheme()
+
+	var missing []string
+	for _, iconName := range il.findIconsHelper(ctx, pending, size, scale, theme, found, opts) {
+		if icon, err := il.lookupFallbackIcon(ctx, iconName, opts); err == nil {
+			found[iconName] = icon
+		} else {
+			missing = append(missing, iconName)
+		}
+	}
+
+	if fallbackTheme != "" {
+		il.findIconsHelper(ctx, missing, size, scale, fallbackTheme, found, opts)
+	}
+
+	return found
+}
+
+// Searches theme and its parents for iconNames, storing hits in found.
+// Returns the names that are still missing.
+func (il *IconLookup) findIconsHelper(ctx context.Context, iconNames []string, size int, scale int, theme string, found map[string]Icon, opts LookupOptions) []string {
+	missing := iconNames
+	for _, chainTheme := range il.themeChain(theme) {
+		if len(missing) == 0 || ctx.Err() != nil {
+			break
+		}
+
+		icons, err := il.lookupIcons(ctx, missing, size, scale, chainTheme, opts)
+		if err != nil {
+			continue
+		}
+
+		var stillMissing []string
+		for _, iconName := range missing {
+			if icon, ok := icons[iconName]; ok {
+				found[iconName] = icon
+			} else {
+				stillMissing = append(stillMissing, iconName)
+			}
+		}
+		missing = stillMissing
+	}
+	return missing
+}
+
+// Looks up all of iconNames in theme alone. Every name is matched in the
+// same order lookupIcon would use, but each subdirectory is only visited
+// once for the whole set.
+func (il *IconLookup) lookupIcons(ctx context.Context, iconNames []string, size int, scale int, theme string, opts LookupOptions) (map[string]Icon, error) {
+	themeInfo, err := il.getThemeInfo(theme)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]Icon)
+
+	// scalable icons of the requested scale win over exact size matches,
+	// closest size first, as they can be rendered at any size
+	if opts.PreferScalable && slices.Contains(opts.Extensions, "svg") {
+		err := il.closestIcons(ctx, themeInfo, theme, iconNames, size, scale, []string{"svg"}, found, func(subdir string) bool {
+			subdirInfo := themeInfo.directoryMap[subdir]
+			if opts.ExactSize && !il.directoryMatchesSize(themeInfo, subdir, size, scale) {
+				return false
+			}
+			return subdirInfo.Type == "Scalable" && subdirInfo.Scale == scale
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, subdir := range themeInfo.allDirectories() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !il.directoryInContext(themeInfo, subdir) || !il.directoryMatchesSize(themeInfo, subdir, size, scale) {
+			continue
+		}
+		dir := path.Join(theme, subdir)
+		for _, directory := range il.getBaseDirs() {
+			for _, iconName := range iconNames {
+				if _, ok := found[iconName]; ok {
+					continue
+				}
+				if iconPath, ok := il.findFile(ctx, directory, dir, iconName, opts.Extensions); ok {
+					found[iconName] = themeIcon(themeInfo, theme, subdir, iconName, iconPath)
+				}
+			}
+		}
+	}
+	if len(found) == len(iconNames) || opts.ExactSize {
+		return found, nil
+	}
+
+	err = il.closestIcons(ctx, themeInfo, theme, iconNames, size, scale, opts.Extensions, found, func(string) bool {
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// Adds the icon of the closest size in theme to found, for every name in
+// iconNames that isn't in found yet. Only subdirectories accepted by
+// include are searched.
+func (il *IconLookup) closestIcons(ctx context.Context, themeInfo ThemeInfo, theme string, iconNames []string, size int, scale int, extensions []string, found map[string]Icon, include func(subdir string) bool) error {
+	type closest struct {
+		distance int
+		path     string
+		subdir   string
+	}
+	closestMatches := make(map[string]closest)
+
+	for _, subdir := range themeInfo.allDirectories() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !il.directoryInContext(themeInfo, subdir) || !include(subdir) {
+			continue
+		}
+		distance := il.directorySizeDistance(themeInfo, subdir, size, scale)
+		dir := path.Join(theme, subdir)
+		for _, directory := range il.getBaseDirs() {
+			for _, iconName := range iconNames {
+				if _, ok := found[iconName]; ok {
+					continue
+				}
+				match, ok := closestMatches[iconName]
+				if ok && distance >= match.distance {
+					continue
+				}
+				if iconPath, ok := il.findFile(ctx, directory, dir, iconName, extensions); ok {
+					closestMatches[iconName] = closest{distance, iconPath, subdir}
+				}
+			}
+		}
+	}
+	for iconName, match := range closestMatches {
+		found[iconName] = themeIcon(themeInfo, theme, match.subdir, iconName, match.path)
+	}
+
+	return nil
+}
+
+func themeIcon(themeInfo ThemeInfo, theme, subdir, iconName, iconPath string) Icon {
+	iconInfo := themeInfo.directoryMap[subdir]
+	return Icon{
+		Name:    iconName,
+		Path:    iconPath,
+		Theme:   theme,
+		Dir:     subdir,
+		Context: iconInfo.Context,
+		Size:    iconInfo.Size,
+		MinSize: iconInfo.MinSize,
+		MaxSize: iconInfo.MaxSize,
+		Scale:   iconInfo.Scale,
+	}
+}
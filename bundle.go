@@ -0,0 +1,129 @@
+package xdgicons
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Name of the manifest file written by ExportBundle
+const BundleManifestName = "manifest.json"
+
+// Contents of a bundle written by ExportBundle
+type BundleManifest struct {
+	// Theme the icons were resolved with
+	Theme string `json:"theme"`
+
+	// Time the bundle was created
+	Created time.Time `json:"created"`
+
+	Icons []BundleEntry `json:"icons"`
+
+	// Names that could not be resolved at some of the requested sizes
+	Missing []string `json:"missing,omitempty"`
+}
+
+// A single icon copied into a bundle
+type BundleEntry struct {
+	Name string `json:"name"`
+
+	// Requested size
+	Size int `json:"size"`
+
+	// Path of the copied file, relative to the bundle directory
+	File string `json:"file"`
+
+	// Path the icon was resolved to
+	Source string `json:"source"`
+
+	// Metadata of the resolved icon
+	IconSize    int `json:"iconSize,omitempty"`
+	IconScale   int `json:"iconScale,omitempty"`
+	IconMinSize int `json:"iconMinSize,omitempty"`
+	IconMaxSize int `json:"iconMaxSize,omitempty"`
+}
+
+// Resolves every name at every size and copies the icon files into dest,
+// as dest/<size>/<name>.<ext>, together with a manifest describing them.
+//
+// Icons are resolved with the default scale. Names that cannot be found
+// are listed in the manifest instead of failing the export.
+func (il *IconLookup) ExportBundle(names []string, sizes []int, dest string) error {
+	for _, name := range names {
+		if name == "" || strings.ContainsRune(name, '/') || name == "." || name == ".." {
+			return fmt.Errorf("invalid icon name %q", name)
+		}
+	}
+
+	manifest := BundleManifest{
+		Theme:   il.Theme(),
+		Created: time.Now(),
+	}
+
+	for _, size := range sizes {
+		sizeDir := path.Join(dest, fmt.Sprint(size))
+		if err := os.MkdirAll(sizeDir, 0o755); err != nil {
+			return fmt.Errorf("error creating directory: %v", err)
+		}
+
+		for _, name := range names {
+			icon, err := il.FindIcon(name, size, il.defaultScale)
+			if err != nil {
+				manifest.Missing = append(manifest.Missing, fmt.Sprintf("%s@%d", name, size))
+				continue
+			}
+
+			file := path.Join(fmt.Sprint(size), name+path.Ext(icon.Path))
+			if err := copyFile(icon.Path, path.Join(dest, file)); err != nil {
+				return fmt.Errorf("error copying %q: %v", icon.Path, err)
+			}
+
+			manifest.Icons = append(manifest.Icons, BundleEntry{
+				Name:        name,
+				Size:        size,
+				File:        file,
+				Source:      icon.Path,
+				IconSize:    icon.Size,
+				IconScale:   icon.Scale,
+				IconMinSize: icon.MinSize,
+				IconMaxSize: icon.MaxSize,
+			})
+		}
+	}
+
+	f, err := os.Create(path.Join(dest, BundleManifestName))
+	if err != nil {
+		return fmt.Errorf("error writing manifest: %v", err)
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return fmt.Errorf("error writing manifest: %v", err)
+	}
+	return f.Close()
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
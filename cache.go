@@ -1,107 +1,831 @@
 package xdgicons
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
+	"maps"
+	"math/rand/v2"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// A base directory that couldn't be indexed, usually because it doesn't
+// exist, like ~/.icons on most systems
+type missingDir struct {
+	checked time.Time
+	jitter  time.Duration
+}
+
+// The file index of a base directory.
+//
+// Entries are never modified once stored in dirCache (except for the
+// atomic lastStat), but replaced as a whole, so files can be read
+// without holding il.mu.
 type baseDirIconCache struct {
-	files    map[string]bool
+	icons iconIndex
+
+	// number of files in icons
+	files int
+
+	// themes whose files are listed by an icon-theme.cache
+	// instead of icons, by theme directory name
+	gtkCaches map[string]*gtkIconCache
+
+	// mtimes of the indexed theme directories and everything below
+	// them, since adding an icon to e.g. hicolor/48x48/apps doesn't
+	// change the mtime of the base directory. For themes with an
+	// icon-theme.cache, the theme directory and the cache file
+	dirMtimes map[string]time.Time
+
+	// what the base directory and symlinked theme directories
+	// resolved to, by path, since a link can be replaced
+	// without changing any of the mtimes
+	links map[string]string
+
+	// themes whose directories were indexed, whether
+	// or not they exist in this base directory
+	themes map[string]bool
+
 	mtime    time.Time
-	lastStat time.Time
+	lastScan time.Time
+
+	// unix nanoseconds of the last mtime check
+	lastStat atomic.Int64
+
+	// unix nanoseconds of the last lookup in this entry
+	lastUsed atomic.Int64
+
+	// random delay added to the revalidation interval of this entry
+	jitter time.Duration
 }
 
+// returns a copy of c with the indexed data of theme replaced by scan
+func (c *baseDirIconCache) withTheme(baseDir, theme string, scan themeScan) *baseDirIconCache {
+	themeDir := path.Join(baseDir, theme)
+
+	// the per-directory icons are never modified, so they can be shared
+	icons := maps.Clone(c.icons)
+	maps.DeleteFunc(icons, func(dir string, _ *dirIcons) bool {
+		return dirInTheme(dir, theme)
+	})
+	maps.Copy(icons, scan.icons)
+
+	dirMtimes := maps.Clone(c.dirMtimes)
+	maps.DeleteFunc(dirMtimes, func(dirPath string, _ time.Time) bool {
+		return dirPath == themeDir || strings.HasPrefix(dirPath, themeDir+"/")
+	})
+	maps.Copy(dirMtimes, scan.mtimes)
+
+	links := maps.Clone(c.links)
+	delete(links, themeDir)
+	maps.Copy(links, scan.links)
+
+	themes := maps.Clone(c.themes)
+	themes[theme] = true
+
+	gtkCaches := maps.Clone(c.gtkCaches)
+	if scan.gtkCache != nil {
+		gtkCaches[theme] = scan.gtkCache
+	} else {
+		delete(gtkCaches, theme)
+	}
+
+	cacheEntry := &baseDirIconCache{
+		icons:     icons,
+		files:     icons.count(),
+		gtkCaches: gtkCaches,
+		dirMtimes: dirMtimes,
+		links:     links,
+		themes:    themes,
+		mtime:     c.mtime,
+		lastScan:  c.lastScan,
+		jitter:    c.jitter,
+	}
+	cacheEntry.lastStat.Store(c.lastStat.Load())
+	cacheEntry.lastUsed.Store(c.lastUsed.Load())
+	return cacheEntry
+}
+
+// returns a copy of c without the indexed data of theme
+func (c *baseDirIconCache) withoutTheme(baseDir, theme string) *baseDirIconCache {
+	cacheEntry := c.withTheme(baseDir, theme, themeScan{})
+	delete(cacheEntry.themes, theme)
+	return cacheEntry
+}
+
+// returns the indexed data of theme, as if its directory was scanned
+func (c *baseDirIconCache) themeScan(baseDir, theme string) themeScan {
+	themeDir := path.Join(baseDir, theme)
+	scan := themeScan{
+		icons:    make(iconIndex),
+		gtkCache: c.gtkCaches[theme],
+		mtimes:   make(map[string]time.Time),
+		links:    make(map[string]string),
+	}
+	if target, ok := c.links[themeDir]; ok {
+		scan.links[themeDir] = target
+	}
+	for dir, icons := range c.icons {
+		if dirInTheme(dir, theme) {
+			scan.icons[dir] = icons
+		}
+	}
+	for dirPath, mtime := range c.dirMtimes {
+		if dirPath == themeDir || strings.HasPrefix(dirPath, themeDir+"/") {
+			scan.mtimes[dirPath] = mtime
+		}
+	}
+	return scan
+}
+
+// reports whether all of themes are indexed
+func (c *baseDirIconCache) covers(themes map[string]bool) bool {
+	for theme := range themes {
+		if !c.themes[theme] {
+			return false
+		}
+	}
+	return true
+}
+
+// Reports whether baseDir or one of the indexed theme
+// directories was modified since c was scanned.
+func (c *baseDirIconCache) changed(baseDir string) (bool, error) {
+	stat, err := os.Stat(baseDir)
+	if err != nil {
+		return false, err
+	}
+	if !stat.ModTime().Equal(c.mtime) {
+		return true, nil
+	}
+
+	for dirPath, mtime := range c.dirMtimes {
+		stat, err := os.Stat(dirPath)
+		if err != nil || !stat.ModTime().Equal(mtime) {
+			return true, nil
+		}
+	}
+	for dirPath, target := range c.links {
+		if real, err := filepath.EvalSymlinks(dirPath); err != nil || real != target {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Maximum number of base directories scanned at the same time.
+// Scans mostly wait for the filesystem, so this exceeds the CPU count.
+const scanWorkers = 8
+
 func (il *IconLookup) createInitialCache() {
-	il.mu.Lock()
-	defer il.mu.Unlock()
+	il.cacheBaseDirectories(context.Background(), il.getBaseDirs())
+}
+
+// Indexes every directory in dirs, several at a time, since each can be
+// slow to stat on its own (e.g. on network filesystems or Nix stores).
+func (il *IconLookup) cacheBaseDirectories(ctx context.Context, dirs []string) {
+	var wg sync.WaitGroup
+	var next atomic.Int64
+	for range min(len(dirs), scanWorkers) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(dirs) || ctx.Err() != nil {
+					return
+				}
+				_ = il.cacheBaseDirectory(ctx, dirs[i])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Indexes dirPath and replaces its cache entry. The walk runs without
+// holding il.mu, so it must not be held by the caller.
+//
+// Gives up, leaving the previous entry in place, once ctx is done.
+// Other failures drop the entry.
+func (il *IconLookup) cacheBaseDirectory(ctx context.Context, dirPath string) error {
+	if il.degraded(dirPath) {
+		return errDegraded(dirPath)
+	}
 
-	for _, directory := range GetBaseDirs() {
-		_ = il.cacheBaseDirectory(directory)
+	// a scan finishing late still stores its result
+	var err error
+	if !il.guard(ctx, dirPath, func() { err = il.storeBaseDirectory(ctx, dirPath) }, nil) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return errDegraded(dirPath)
+	}
+	return err
+}
+
+// indexes dirPath and stores the result, see cacheBaseDirectory
+func (il *IconLookup) storeBaseDirectory(ctx context.Context, dirPath string) error {
+	for {
+		il.mu.RLock()
+		generation := il.dirGeneration
+		loadGeneration := il.loadGeneration
+		themes := maps.Clone(il.loadedThemes)
+		il.mu.RUnlock()
+
+		cacheEntry, err := il.indexBaseDirectory(ctx, dirPath, themes)
+		staleThemes := il.themesAffectedBy(dirPath)
+
+		il.mu.Lock()
+		// the cache was reset (e.g. by Reload) while walking
+		if generation != il.dirGeneration {
+			il.mu.Unlock()
+			return nil
+		}
+		// a theme was loaded while walking, whose files would be lost
+		if err == nil && loadGeneration != il.loadGeneration {
+			il.mu.Unlock()
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				delete(il.dirCache, dirPath)
+				delete(il.evictedDirs, dirPath)
+				delete(il.invalidatedDirs, dirPath)
+				il.missingDirs[dirPath] = missingDir{checked: time.Now(), jitter: il.randomJitter()}
+			}
+			il.mu.Unlock()
+			return err
+		}
+
+		// fmt.Println("caching Base Dir")
+		previous := il.dirCache[dirPath]
+		il.dropThemeInfos(staleThemes)
+		il.dirCache[dirPath] = cacheEntry
+		delete(il.evictedDirs, dirPath)
+		delete(il.invalidatedDirs, dirPath)
+		delete(il.missingDirs, dirPath)
+		il.evictCache(dirPath)
+		il.mu.Unlock()
+
+		if previous != nil && previous != cacheEntry {
+			if themes, changed := cacheEntry.changedSince(dirPath, previous); changed {
+				il.notify(ChangeEvent{Kind: IconsChanged, BaseDir: dirPath, Themes: themes})
+			}
+		}
+		return nil
+	}
+}
+
+// Builds a new cache entry for dirPath, without locking.
+// With [LookupConfig.ShareCache], the entry of another lookup is
+// used instead if it is up to date.
+func (il *IconLookup) indexBaseDirectory(ctx context.Context, dirPath string, themes map[string]bool) (*baseDirIconCache, error) {
+	if !il.shareCache {
+		return il.scanBaseDirectory(ctx, dirPath, themes)
+	}
+
+	shared := sharedCache.get(dirPath)
+	if shared != nil && il.pathAllowed(dirPath) && shared.covers(themes) {
+		if changed, err := shared.changed(dirPath); err == nil && !changed {
+			return shared, nil
+		}
+	}
+
+	// index the themes of the other lookups as well,
+	// so they can use the new entry too
+	if shared != nil {
+		themes = maps.Clone(themes)
+		maps.Copy(themes, shared.themes)
+	}
+
+	cacheEntry, err := il.scanBaseDirectory(ctx, dirPath, themes)
+	if err != nil {
+		return nil, err
 	}
+	sharedCache.put(dirPath, cacheEntry)
+	return cacheEntry, nil
 }
 
-func (il *IconLookup) cacheBaseDirectory(dirPath string) error {
+// Builds a new cache entry for dirPath by scanning it, without locking.
+//
+// Only the files directly in dirPath and the directories of the
+// given themes are indexed, other themes are left to [IconLookup.loadTheme].
+func (il *IconLookup) scanBaseDirectory(ctx context.Context, dirPath string, themes map[string]bool) (*baseDirIconCache, error) {
+	if !il.pathAllowed(dirPath) {
+		return nil, fmt.Errorf("base directory %q is outside of the allowed roots", dirPath)
+	}
+
 	stat, err := os.Stat(dirPath)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	icons := make(iconIndex)
+	gtkCaches := make(map[string]*gtkIconCache)
+	dirMtimes := make(map[string]time.Time)
+	links := make(map[string]string)
+	if target, ok := resolveLink(dirPath); ok {
+		links[dirPath] = target
 	}
 
-	files := make(map[string]bool)
+	// unreadable directories are cached as empty
+	entries, _ := os.ReadDir(dirPath)
+	for _, entry := range entries {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if entry.Type()&fs.ModeSymlink != 0 && !il.pathAllowed(path.Join(dirPath, entry.Name())) {
+			continue
+		}
+		if !isDirEntry(dirPath, entry) {
+			icons.add(entry.Name())
+			continue
+		}
+		if !themes[entry.Name()] {
+			continue
+		}
 
-	err = filepath.WalkDir(dirPath, func(subPath string, d os.DirEntry, err error) error {
+		scan, err := scanTheme(ctx, dirPath, entry.Name(), il.pathAllowed)
 		if err != nil {
-			return nil
+			return nil, err
 		}
-		if !d.IsDir() {
-			files[subPath] = true
+		maps.Copy(icons, scan.icons)
+		maps.Copy(dirMtimes, scan.mtimes)
+		maps.Copy(links, scan.links)
+		if scan.gtkCache != nil {
+			gtkCaches[entry.Name()] = scan.gtkCache
 		}
-		return nil
+	}
+	icons.sort()
+
+	now := time.Now()
+	cacheEntry := &baseDirIconCache{
+		icons:     icons,
+		files:     icons.count(),
+		gtkCaches: gtkCaches,
+		dirMtimes: dirMtimes,
+		links:     links,
+		themes:    themes,
+		mtime:     stat.ModTime(),
+		lastScan:  now,
+		jitter:    il.randomJitter(),
+	}
+	cacheEntry.lastStat.Store(now.UnixNano())
+	cacheEntry.lastUsed.Store(now.UnixNano())
+	return cacheEntry, nil
+}
+
+// The indexed data of a single theme directory
+type themeScan struct {
+	icons    iconIndex
+	gtkCache *gtkIconCache
+	mtimes   map[string]time.Time
+	links    map[string]string
+}
+
+// Indexes the directory of theme in baseDir, or returns its
+// icon-theme.cache instead if it is up to date. Nothing that
+// allowed rejects is read.
+func scanTheme(ctx context.Context, baseDir, theme string, allowed func(string) bool) (themeScan, error) {
+	themeDir := path.Join(baseDir, theme)
+	scan := themeScan{
+		icons:  make(iconIndex),
+		mtimes: make(map[string]time.Time),
+		links:  make(map[string]string),
+	}
+	// only the link itself, the base directory's links are its own
+	if stat, err := os.Lstat(themeDir); err == nil && stat.Mode()&fs.ModeSymlink != 0 {
+		if target, ok := resolveLink(themeDir); ok {
+			scan.links[themeDir] = target
+		}
+	}
+
+	// e.g. a theme linked in from outside of the allowed roots
+	if !allowed(themeDir) {
+		return scan, nil
+	}
+
+	if gtkCache := openGTKIconCache(themeDir); gtkCache != nil {
+		scan.gtkCache = gtkCache
+		for _, p := range []string{themeDir, path.Join(themeDir, gtkIconCacheName)} {
+			if stat, err := os.Stat(p); err == nil {
+				scan.mtimes[p] = stat.ModTime()
+			}
+		}
+		return scan, nil
+	}
+
+	err := walkFollowingLinks(ctx, themeDir, allowed, func(dirPath string, info fs.FileInfo) {
+		scan.mtimes[dirPath] = info.ModTime()
+	}, func(filePath string) {
+		scan.icons.add(theme + strings.TrimPrefix(filePath, themeDir))
 	})
 	if err != nil {
-		return err
+		return themeScan{}, err
 	}
+	scan.icons.sort()
+	return scan, nil
+}
 
-	// fmt.Println("caching Base Dir")
-	il.clearThemeInfoCache()
-	il.dirCache[dirPath] = &baseDirIconCache{
-		files:    files,
-		mtime:    stat.ModTime(),
-		lastStat: time.Now(),
+// Indexes the directories of theme in every base directory, the
+// first time it is used by a lookup. The walk runs without holding
+// il.mu, so it must not be held by the caller.
+//
+// With [LookupConfig.ShareCache] and reuse set, themes already
+// indexed by other lookups are taken from their entries.
+func (il *IconLookup) loadTheme(theme string, reuse bool) {
+	reuse = reuse && il.shareCache
+
+	scans := make(map[string]themeScan)
+	shared := make(map[string]*baseDirIconCache)
+	for _, baseDir := range il.getBaseDirs() {
+		if il.degraded(baseDir) || !il.pathAllowed(baseDir) {
+			continue
+		}
+		if reuse {
+			if cacheEntry := sharedCache.get(baseDir); cacheEntry != nil && cacheEntry.themes[theme] {
+				shared[baseDir] = cacheEntry
+				continue
+			}
+		}
+		var scan themeScan
+		var err error
+		inTime := il.guard(context.Background(), baseDir, func() {
+			scan, err = scanTheme(context.Background(), baseDir, theme, il.pathAllowed)
+		}, func() {
+			// the theme is indexed along with the loaded ones
+			il.refreshBaseDirectory(context.Background(), baseDir)
+		})
+		if !inTime || err != nil {
+			continue
+		}
+		scans[baseDir] = scan
 	}
 
-	return nil
+	il.mu.Lock()
+	defer il.mu.Unlock()
+
+	if il.loadedThemes[theme] {
+		return
+	}
+	il.loadedThemes[theme] = true
+	il.loadGeneration++
+
+	// base directories without an entry yet will
+	// include the theme once they are scanned
+	for _, baseDir := range il.baseDirs {
+		cacheEntry := il.dirCache[baseDir]
+		if cacheEntry == nil {
+			continue
+		}
+
+		if il.shareCache {
+			// build on the entry of the other lookups, so the result can be shared
+			if sharedEntry := sharedCache.get(baseDir); sharedEntry != nil && sharedEntry.covers(cacheEntry.themes) {
+				cacheEntry = sharedEntry
+			}
+		}
+
+		scan, ok := scans[baseDir]
+		if reuse && cacheEntry.themes[theme] {
+			il.dirCache[baseDir] = cacheEntry
+			continue
+		}
+		if sharedEntry := shared[baseDir]; sharedEntry != nil {
+			scan, ok = sharedEntry.themeScan(baseDir, theme), true
+		}
+		if !ok {
+			continue
+		}
+		cacheEntry = cacheEntry.withTheme(baseDir, theme, scan)
+		il.dirCache[baseDir] = cacheEntry
+		if il.shareCache {
+			sharedCache.put(baseDir, cacheEntry)
+		}
+	}
+	il.evictCache("")
+}
+
+// Drops the least recently used base directories from the cache until
+// it holds at most il.maxCachedFiles files. The entry of keep, which
+// was just stored, and entries used within the last second are never
+// dropped. Must be called with il.mu held.
+func (il *IconLookup) evictCache(keep string) {
+	if il.maxCachedFiles <= 0 {
+		return
+	}
+
+	total := 0
+	for _, cacheEntry := range il.dirCache {
+		total += cacheEntry.files
+	}
+
+	// entries used by the current lookups would just be scanned
+	// again right away, so they are kept even if over the limit
+	recent := time.Now().Add(-time.Second).UnixNano()
+
+	for total > il.maxCachedFiles {
+		var oldest string
+		var oldestUsed int64
+		for baseDir, cacheEntry := range il.dirCache {
+			// dropping empty entries would only cause rescans
+			if baseDir == keep || cacheEntry.files == 0 {
+				continue
+			}
+			if cacheEntry.lastUsed.Load() > recent {
+				continue
+			}
+			if used := cacheEntry.lastUsed.Load(); oldest == "" || used < oldestUsed {
+				oldest, oldestUsed = baseDir, used
+			}
+		}
+		if oldest == "" {
+			return
+		}
+
+		total -= il.dirCache[oldest].files
+		delete(il.dirCache, oldest)
+		il.evictedDirs[oldest] = true
+		il.evictions++
+	}
+}
+
+// Rescans baseDir for a lookup that found its cache entry outdated.
+//
+// Concurrent refreshes of the same directory share a single walk. While
+// it runs, lookups keep using the previous entry, if there is one.
+func (il *IconLookup) refreshBaseDirectory(ctx context.Context, baseDir string) *baseDirIconCache {
+	il.mu.Lock()
+	scan, running := il.scans[baseDir]
+	if !running {
+		scan = make(chan struct{})
+		il.scans[baseDir] = scan
+	}
+	cacheEntry := il.dirCache[baseDir]
+	il.mu.Unlock()
+
+	if running {
+		if cacheEntry != nil {
+			return cacheEntry
+		}
+		select {
+		case <-scan:
+		case <-ctx.Done():
+			return nil
+		}
+	} else {
+		_ = il.cacheBaseDirectory(ctx, baseDir)
+
+		il.mu.Lock()
+		delete(il.scans, baseDir)
+		il.mu.Unlock()
+		close(scan)
+	}
+
+	il.mu.RLock()
+	defer il.mu.RUnlock()
+	return il.dirCache[baseDir]
 }
 
 func (il *IconLookup) getThemeInfo(theme string) (ThemeInfo, error) {
+	il.touchTheme(theme)
+	return il.readThemeInfo(theme)
+}
+
+// Records warning in the cached ThemeInfo of theme, unless it was
+// recorded before, and reports it to OnThemeWarning. Used for problems
+// only found during lookups, e.g. inheritance cycles.
+func (il *IconLookup) addThemeWarning(theme, warning string) {
+	il.mu.Lock()
+	themeInfo, ok := il.themeInfoCache[theme]
+	if !ok || slices.Contains(themeInfo.Warnings, warning) {
+		il.mu.Unlock()
+		return
+	}
+	// the cached lists are shared with callers, so never append in place
+	themeInfo.Warnings = append(slices.Clip(themeInfo.Warnings), warning)
+	il.themeInfoCache[theme] = themeInfo
+	il.mu.Unlock()
+
+	if il.onThemeWarning != nil {
+		il.onThemeWarning(theme, warning)
+	}
+}
+
+// returns the parsed index.theme of theme, without indexing its icons
+func (il *IconLookup) readThemeInfo(theme string) (ThemeInfo, error) {
 	il.mu.RLock()
 	themeInfo, ok := il.themeInfoCache[theme]
+	missing := il.missingThemes[theme]
+	generation := il.themeGeneration
 	il.mu.RUnlock()
 
 	if ok {
 		return themeInfo, nil
 	}
+	if missing {
+		return ThemeInfo{}, &ThemeNotFoundError{Theme: theme}
+	}
 
-	for _, directory := range GetBaseDirs() {
-		indexPath := path.Join(directory, theme, "index.theme")
-		_, err := os.Stat(indexPath)
-		if err != nil {
+	// a theme can be installed in several base directories, e.g. hicolor
+	// in /usr/share/icons and ~/.local/share/icons, each index.theme
+	// describing the directories installed there
+	var merged *ThemeInfo
+	var indexErr error
+	for _, directory := range il.getBaseDirs() {
+		if il.degraded(directory) {
 			continue
 		}
-		themeInfo, err := il.readThemeIndex(theme, indexPath)
-		if err != nil {
+
+		var themeInfo *ThemeInfo
+		var warning string
+		var err error
+		inTime := il.guard(context.Background(), directory, func() {
+			var indexPath string
+			var ok bool
+			indexPath, warning, ok = findThemeIndex(path.Join(directory, theme))
+			if !ok || !il.pathAllowed(indexPath) {
+				err = ErrThemeNotFound
+				return
+			}
+			themeInfo, err = il.readThemeIndex(theme, indexPath)
+			if err == nil && warning != "" && il.strictThemeIndex {
+				err = fmt.Errorf("invalid index.theme: %s", warning)
+			}
+		}, func() {
+			// the theme may have been taken from a later base directory
+			il.mu.Lock()
+			il.dropThemeInfos([]string{theme})
+			delete(il.missingThemes, theme)
+			il.mu.Unlock()
+		})
+		if !inTime || err != nil {
+			if indexErr == nil && err != nil && err != ErrThemeNotFound {
+				indexErr = err
+			}
 			continue
 		}
+		if warning != "" {
+			themeInfo.Warnings = append([]string{warning}, themeInfo.Warnings...)
+		}
+		themeInfo.baseDirs = []string{directory}
+		if merged == nil {
+			merged = themeInfo
+		} else {
+			merged.merge(themeInfo)
+		}
+	}
+
+	if merged != nil {
+		if il.onThemeWarning != nil {
+			for _, warning := range merged.Warnings {
+				il.onThemeWarning(theme, warning)
+			}
+		}
+
+		il.mu.Lock()
+		// don't resurrect data of a cache that was cleared while parsing
+		if generation == il.themeGeneration {
+			il.themeInfoCache[theme] = *merged
+		}
+		il.mu.Unlock()
+		return *merged, nil
+	}
+
+	// only remembered while watching, since nothing else
+	// would notice the theme being installed later
+	il.mu.Lock()
+	if il.watcher != nil {
+		il.missingThemes[theme] = true
+	}
+	il.mu.Unlock()
+
+	return ThemeInfo{}, &ThemeNotFoundError{Theme: theme, Err: indexErr}
+}
 
-		il.themeInfoCache[theme] = *themeInfo
-		return *themeInfo, nil
+// Lists the cached themes whose index.theme may have changed with
+// baseDir: the ones read from it, and the ones it now has an
+// index.theme for, which is merged into theirs.
+func (il *IconLookup) themesAffectedBy(baseDir string) []string {
+	il.mu.RLock()
+	baseDirs := il.baseDirs
+	sources := make(map[string][]string, len(il.themeInfoCache))
+	for theme, themeInfo := range il.themeInfoCache {
+		sources[theme] = themeInfo.baseDirs
+	}
+	il.mu.RUnlock()
+
+	searched := slices.Contains(baseDirs, baseDir)
+
+	var themes []string
+	for theme, themeSources := range sources {
+		if slices.Contains(themeSources, baseDir) {
+			themes = append(themes, theme)
+			continue
+		}
+		if !searched {
+			continue
+		}
+		if _, _, ok := findThemeIndex(path.Join(baseDir, theme)); ok {
+			themes = append(themes, theme)
+		}
 	}
+	return themes
+}
 
-	return ThemeInfo{}, fmt.Errorf("theme %q not found", theme)
+// Drops the parsed index.theme of themes, making sure index.theme
+// files being parsed right now aren't cached either, since they may
+// be outdated as well. Must be called with il.mu held.
+func (il *IconLookup) dropThemeInfos(themes []string) {
+	for _, theme := range themes {
+		delete(il.themeInfoCache, theme)
+	}
+	il.themeGeneration++
 }
 
+// must be called with il.mu held
 func (il *IconLookup) clearThemeInfoCache() {
 	il.themeInfoCache = make(map[string]ThemeInfo)
+	il.themeGeneration++
 }
 
 func (il *IconLookup) shouldRefreshCache(baseDir string, cacheEntry *baseDirIconCache, now time.Time) bool {
 	if cacheEntry == nil {
-		return true
+		return il.appeared(baseDir, now)
 	}
 
-	if now.Sub(cacheEntry.lastStat) < il.cacheValidCheckInterval {
+	if now.Sub(time.Unix(0, cacheEntry.lastStat.Load())) < il.checkInterval(baseDir)+cacheEntry.jitter {
 		return false
 	}
 
-	stat, err := os.Stat(baseDir)
+	// the mtime is left untouched, so the change is
+	// picked up by the first check after the interval
+	if now.Sub(cacheEntry.lastScan) < il.minRescanInterval {
+		return false
+	}
+
+	var changed bool
+	var err error
+	inTime := il.guard(context.Background(), baseDir, func() {
+		changed, err = cacheEntry.changed(baseDir)
+	}, func() {
+		go il.refreshBaseDirectory(context.Background(), baseDir)
+	})
+	if !inTime {
+		return false
+	}
 	if err != nil {
 		il.mu.Lock()
-		delete(il.dirCache, baseDir)
+		if il.dirCache[baseDir] == cacheEntry {
+			delete(il.dirCache, baseDir)
+		}
 		il.mu.Unlock()
 		return false
 	}
+	return changed
+}
+
+// Reports whether baseDir, which has no cache entry, can be indexed now.
+// Like entries, it's only checked once per interval, since lookups
+// would otherwise stat missing base directories every time.
+func (il *IconLookup) appeared(baseDir string, now time.Time) bool {
+	il.mu.Lock()
+	missing, ok := il.missingDirs[baseDir]
+	if ok && now.Sub(missing.checked) < il.checkInterval(baseDir)+missing.jitter {
+		il.mu.Unlock()
+		return false
+	}
+	// recorded up front, so concurrent lookups don't wait for the same stat
+	il.missingDirs[baseDir] = missingDir{checked: now, jitter: il.randomJitter()}
+	il.mu.Unlock()
+
+	// mounts of base directories can hang, like walks of them
+	var err error
+	if !il.guard(context.Background(), baseDir, func() { _, err = os.Stat(baseDir) }, nil) {
+		return false
+	}
+	return err == nil
+}
 
-	return !stat.ModTime().Equal(cacheEntry.mtime)
+// returns how often baseDir is checked for changes, negative if never
+func (il *IconLookup) checkInterval(baseDir string) time.Duration {
+	if interval, ok := il.checkIntervals[baseDir]; ok {
+		return interval
+	}
+	return il.cacheValidCheckInterval
+}
+
+// returns a random delay up to the configured jitter
+func (il *IconLookup) randomJitter() time.Duration {
+	if il.rescanJitter <= 0 {
+		return 0
+	}
+	return rand.N(il.rescanJitter)
 }
@@ -0,0 +1,93 @@
+package xdgicons
+
+import (
+	"context"
+	"path"
+	"slices"
+)
+
+// Lists every file that could be used for iconName at the given size
+// and scale, across all searched themes and base directories.
+//
+// Candidates are sorted by how far their size is from the requested one,
+// ties are broken by theme priority (the order [IconLookup.FindIcon]
+// searches themes in). Icons outside of any theme come last.
+func (il *IconLookup) FindIconCandidates(iconName string, size int, scale int) ([]Icon, error) {
+	ctx := context.Background()
+	icon, iconName, ok := il.applyOverride(iconName, il.extensions)
+	if ok {
+		return []Icon{icon}, nil
+	}
+
+	theme, fallbackTheme := il.Theme(), il.FallbackTheme()
+	chain := il.themeChain(theme)
+	if fallbackTheme != "" {
+		for _, fallback := range il.themeChain(fallbackTheme) {
+			if !slices.Contains(chain, fallback) {
+				chain = append(chain, fallback)
+			}
+		}
+	}
+
+	type candidate struct {
+		icon     Icon
+		distance int
+		priority int
+	}
+	var candidates []candidate
+	seen := make(map[string]bool)
+
+	for priority, chainTheme := range chain {
+		themeInfo, err := il.getThemeInfo(chainTheme)
+		if err != nil {
+			continue
+		}
+		for _, subdir := range themeInfo.allDirectories() {
+			if !il.directoryInContext(themeInfo, subdir) {
+				continue
+			}
+			distance := il.directorySizeDistance(themeInfo, subdir, size, scale)
+			for _, directory := range il.getBaseDirs() {
+				for _, extension := range il.extensions {
+					iconPath, ok := il.findFile(ctx, directory, path.Join(chainTheme, subdir), iconName, []string{extension})
+					if !ok || seen[iconPath] {
+						continue
+					}
+					seen[iconPath] = true
+					candidates = append(candidates, candidate{
+						icon:     themeIcon(themeInfo, chainTheme, subdir, iconName, iconPath),
+						distance: distance,
+						priority: priority,
+					})
+				}
+			}
+		}
+	}
+
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		if a.distance != b.distance {
+			return a.distance - b.distance
+		}
+		return a.priority - b.priority
+	})
+
+	icons := make([]Icon, 0, len(candidates))
+	for _, c := range candidates {
+		icons = append(icons, c.icon)
+	}
+
+	if !il.disablePixmapFallback {
+		for _, directory := range il.getBaseDirs() {
+			for _, extension := range il.extensions {
+				if iconPath, ok := il.findFile(ctx, directory, "", iconName, []string{extension}); ok {
+					icons = append(icons, Icon{Name: iconName, Path: iconPath})
+				}
+			}
+		}
+	}
+
+	if len(icons) == 0 {
+		return nil, il.iconNotFound([]string{iconName}, il.lookupOptions(LookupOptions{}))
+	}
+	return icons, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 
 	// "log"
 	"os"
@@ -13,6 +14,32 @@ import (
 )
 
 func main() {
+	if len(os.Args) == 3 && os.Args[1] == "update-cache" {
+		if err := xdgicons.UpdateGTKIconCache(os.Args[2]); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+	if len(os.Args) == 3 && os.Args[1] == "validate" {
+		failed := false
+		for _, problem := range xdgicons.ValidateTheme(os.Args[2]) {
+			fmt.Println(problem)
+			failed = failed || !problem.Warning
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) == 3 && os.Args[1] == "tree" {
+		tree, err := xdgicons.NewIconLookup().ThemeTree(os.Args[2])
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		printThemeTree(tree, 0)
+		return
+	}
+
 	size, _ := strconv.Atoi(os.Args[2])
 	scale, _ := strconv.Atoi(os.Args[3])
 	t := time.Now()
@@ -38,3 +65,21 @@ func main() {
 	}
 	fmt.Printf("%s", icon.Path)
 }
+
+func printThemeTree(node *xdgicons.ThemeNode, depth int) {
+	var note string
+	switch {
+	case node.Cycle:
+		note = " (cycle, skipped)"
+	case node.Duplicate:
+		note = " (searched before)"
+	case !node.Installed:
+		note = " (not installed)"
+	default:
+		note = fmt.Sprintf(" #%d", node.Order+1)
+	}
+	fmt.Printf("%s%s%s\n", strings.Repeat("  ", depth), node.Theme, note)
+	for _, parent := range node.Parents {
+		printThemeTree(parent, depth+1)
+	}
+}
@@ -0,0 +1,77 @@
+package xdgicons
+
+import (
+	"slices"
+	"strings"
+)
+
+// Appearance the theme should match, see [LookupConfig.ColorScheme]
+type ColorScheme int
+
+const (
+	// Use the configured theme as is
+	NoColorScheme ColorScheme = iota
+
+	// Prefer the dark variant of the theme, e.g. Papirus-Dark
+	PreferDark
+
+	// Prefer the light variant of the theme, e.g. Papirus-Light
+	PreferLight
+
+	// Prefer the variant matching the color-scheme setting
+	// of the settings portal, if it has a preference
+	SystemColorScheme
+)
+
+func (s ColorScheme) String() string {
+	switch s {
+	case NoColorScheme:
+		return "NoColorScheme"
+	case PreferDark:
+		return "PreferDark"
+	case PreferLight:
+		return "PreferLight"
+	case SystemColorScheme:
+		return "SystemColorScheme"
+	}
+	return "ColorScheme(?)"
+}
+
+// suffixes of the names of theme variants, in order of preference
+var (
+	darkVariantSuffixes  = []string{"-Dark", "-dark"}
+	lightVariantSuffixes = []string{"-Light", "-light"}
+)
+
+// Returns the variant of theme matching the configured color scheme.
+// Falls back to the base theme, e.g. Papirus for Papirus-Dark, if
+// there's no such variant, and to theme if that isn't installed either.
+func (il *IconLookup) themeVariant(theme string) string {
+	scheme := il.colorScheme
+	if scheme == SystemColorScheme {
+		scheme = portalColorScheme()
+	}
+	suffixes := darkVariantSuffixes
+	switch scheme {
+	case PreferDark:
+	case PreferLight:
+		suffixes = lightVariantSuffixes
+	default:
+		return theme
+	}
+
+	base := theme
+	for _, suffix := range slices.Concat(darkVariantSuffixes, lightVariantSuffixes) {
+		if trimmed, ok := strings.CutSuffix(theme, suffix); ok {
+			base = trimmed
+			break
+		}
+	}
+
+	for _, variant := range append(suffixes, "") {
+		if _, err := il.readThemeInfo(base + variant); err == nil {
+			return base + variant
+		}
+	}
+	return theme
+}
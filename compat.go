@@ -0,0 +1,127 @@
+package xdgicons
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Compatibility with non-standard index.theme files found in the wild.
+// Quirks are normalized and reported in ThemeInfo.Warnings instead of
+// making the whole theme unusable.
+
+// returns the path of the index.theme of themeDir, accepting
+// differently capitalized file names like "Index.theme"
+func findThemeIndex(themeDir string) (indexPath string, warning string, ok bool) {
+	indexPath = path.Join(themeDir, "index.theme")
+	if _, err := os.Stat(indexPath); err == nil {
+		return indexPath, "", true
+	}
+
+	entries, err := os.ReadDir(themeDir)
+	if err != nil {
+		return "", "", false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(entry.Name(), "index.theme") {
+			return path.Join(themeDir, entry.Name()),
+				fmt.Sprintf("index file is named %q instead of \"index.theme\"", entry.Name()), true
+		}
+	}
+
+	return "", "", false
+}
+
+// returns the names of sections that appear more than once in data,
+// which the ini parser silently merges
+func duplicateSections(data []byte) []string {
+	seen := make(map[string]bool)
+	var duplicates []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+			continue
+		}
+
+		section := line[1 : len(line)-1]
+		if seen[section] {
+			duplicates = append(duplicates, section)
+		}
+		seen[section] = true
+	}
+
+	return duplicates
+}
+
+// splits a directory list, dropping empty entries and trailing slashes
+func normalizeDirList(key *ini.Key, warnings *[]string) []string {
+	var dirs []string
+	for _, dir := range key.Strings(",") {
+		if dir == "" {
+			continue
+		}
+		if trimmed := strings.TrimRight(dir, "/"); trimmed != dir {
+			*warnings = append(*warnings, fmt.Sprintf("directory %q listed with trailing slash", dir))
+			dir = trimmed
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// returns the section describing dir, which may have been
+// written with the trailing slash that was stripped from its name
+func findDirSection(index *ini.File, dir string) (*ini.Section, error) {
+	section, err := index.GetSection(dir)
+	if err == nil {
+		return section, nil
+	}
+	if section, err := index.GetSection(dir + "/"); err == nil {
+		return section, nil
+	}
+	return nil, err
+}
+
+// parses a size value, accepting "16x16" style values
+func parseSize(value string) (size int, quirk bool, err error) {
+	value = strings.TrimSpace(value)
+	if size, err := strconv.Atoi(value); err == nil {
+		return size, false, nil
+	}
+
+	width, height, found := strings.Cut(strings.ToLower(value), "x")
+	if found {
+		size, err := strconv.Atoi(width)
+		if err == nil && width == height {
+			return size, true, nil
+		}
+	}
+
+	return 0, false, fmt.Errorf("invalid size %q", value)
+}
+
+// reads an optional size key of section, falling back to def
+func sizeKey(section *ini.Section, dir, name string, def int, warnings *[]string) int {
+	key, err := section.GetKey(name)
+	if err != nil {
+		return def
+	}
+
+	size, quirk, err := parseSize(key.String())
+	if err != nil {
+		*warnings = append(*warnings, fmt.Sprintf("[%s] %s: %v", dir, name, err))
+		return def
+	}
+	if quirk {
+		*warnings = append(*warnings, fmt.Sprintf("[%s] %s=%s is not a plain number", dir, name, key.String()))
+	}
+	return size
+}
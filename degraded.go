@@ -0,0 +1,70 @@
+package xdgicons
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Runs op, which touches the filesystem of baseDir, waiting at most
+// il.scanTimeout for it. Syscalls on a hung NFS or FUSE mount can't
+// be interrupted, so if op takes longer it is left running, and
+// baseDir is marked degraded until it returns. recovered is then
+// called, if set, to bring baseDir up to date.
+//
+// Reports whether op returned in time.
+func (il *IconLookup) guard(ctx context.Context, baseDir string, op func(), recovered func()) bool {
+	if il.scanTimeout < 0 {
+		op()
+		return true
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		op()
+	}()
+
+	timer := time.NewTimer(il.scanTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+	}
+
+	il.mu.Lock()
+	il.degradedDirs[baseDir]++
+	il.mu.Unlock()
+
+	go func() {
+		<-done
+
+		il.mu.Lock()
+		il.degradedDirs[baseDir]--
+		if il.degradedDirs[baseDir] <= 0 {
+			delete(il.degradedDirs, baseDir)
+		}
+		il.mu.Unlock()
+
+		if recovered != nil {
+			recovered()
+		}
+	}()
+	return false
+}
+
+// reports whether an operation on baseDir is stuck
+func (il *IconLookup) degraded(baseDir string) bool {
+	il.mu.RLock()
+	defer il.mu.RUnlock()
+	return il.degradedDirs[baseDir] > 0
+}
+
+// error returned for base directories that are skipped while degraded
+func errDegraded(baseDir string) error {
+	return fmt.Errorf("base directory %q is not responding", baseDir)
+}
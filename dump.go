@@ -0,0 +1,138 @@
+package xdgicons
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+)
+
+// What an IconLookup currently knows about the filesystem,
+// as written by [IconLookup.DumpCache]
+type cacheDump struct {
+	Theme         string          `json:"theme"`
+	FallbackTheme string          `json:"fallbackTheme,omitempty"`
+	Extensions    []string        `json:"extensions"`
+	Created       time.Time       `json:"created"`
+	BaseDirs      []baseDirDump   `json:"baseDirs"`
+	Themes        []themeInfoDump `json:"themes"`
+	MissingThemes []string        `json:"missingThemes,omitempty"`
+	LoadedThemes  []string        `json:"loadedThemes"`
+}
+
+type baseDirDump struct {
+	Path        string               `json:"path"`
+	Cached      bool                 `json:"cached"`
+	Evicted     bool                 `json:"evicted,omitempty"`
+	Invalidated bool                 `json:"invalidated,omitempty"`
+	Degraded    bool                 `json:"degraded,omitempty"`
+	Mtime       time.Time            `json:"mtime,omitzero"`
+	LastScan    time.Time            `json:"lastScan,omitzero"`
+	LastCheck   time.Time            `json:"lastCheck,omitzero"`
+	LastUsed    time.Time            `json:"lastUsed,omitzero"`
+	Themes      []string             `json:"themes,omitempty"`
+	GTKCaches   []string             `json:"gtkCaches,omitempty"`
+	DirMtimes   map[string]time.Time `json:"dirMtimes,omitempty"`
+	Links       map[string]string    `json:"links,omitempty"`
+
+	// directory relative to the base directory -> icon files in it
+	Directories map[string][]string `json:"directories,omitempty"`
+}
+
+type themeInfoDump struct {
+	Theme       string    `json:"theme"`
+	Name        string    `json:"name"`
+	BaseDirs    []string  `json:"baseDirs"`
+	Inherits    []string  `json:"inherits,omitempty"`
+	Directories []string  `json:"directories"`
+	LastUsed    time.Time `json:"lastUsed,omitzero"`
+}
+
+// Writes everything the lookup has cached as indented JSON: every base
+// directory with the files indexed in it and the times it was scanned
+// and checked, and the parsed index.theme of every theme. Meant for
+// debugging lookups that don't find icons which exist on disk.
+func (il *IconLookup) DumpCache(w io.Writer) error {
+	il.mu.RLock()
+	dump := cacheDump{
+		Theme:         il.theme,
+		FallbackTheme: il.fallbackTheme,
+		Extensions:    il.extensions,
+		Created:       time.Now(),
+		MissingThemes: slices.Sorted(maps.Keys(il.missingThemes)),
+		LoadedThemes:  slices.Sorted(maps.Keys(il.loadedThemes)),
+	}
+
+	for _, baseDir := range il.baseDirs {
+		dirDump := baseDirDump{
+			Path:        baseDir,
+			Evicted:     il.evictedDirs[baseDir],
+			Invalidated: il.invalidatedDirs[baseDir],
+			Degraded:    il.degradedDirs[baseDir] > 0,
+		}
+		if cacheEntry := il.dirCache[baseDir]; cacheEntry != nil {
+			dirDump.Cached = true
+			dirDump.Mtime = cacheEntry.mtime
+			dirDump.LastScan = cacheEntry.lastScan
+			dirDump.LastCheck = time.Unix(0, cacheEntry.lastStat.Load())
+			dirDump.LastUsed = time.Unix(0, cacheEntry.lastUsed.Load())
+			dirDump.Themes = slices.Sorted(maps.Keys(cacheEntry.themes))
+			dirDump.GTKCaches = slices.Sorted(maps.Keys(cacheEntry.gtkCaches))
+			dirDump.DirMtimes = cacheEntry.dirMtimes
+			dirDump.Links = cacheEntry.links
+			dirDump.Directories = cacheEntry.dump()
+		}
+		dump.BaseDirs = append(dump.BaseDirs, dirDump)
+	}
+
+	for _, theme := range slices.Sorted(maps.Keys(il.themeInfoCache)) {
+		themeInfo := il.themeInfoCache[theme]
+		dump.Themes = append(dump.Themes, themeInfoDump{
+			Theme:       theme,
+			Name:        themeInfo.Name,
+			BaseDirs:    themeInfo.baseDirs,
+			Inherits:    themeInfo.Inherits,
+			Directories: themeInfo.allDirectories(),
+			LastUsed:    il.themeLastUsed[theme],
+		})
+	}
+	il.mu.RUnlock()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(dump); err != nil {
+		return fmt.Errorf("error writing cache dump: %v", err)
+	}
+	return nil
+}
+
+// Lists the icon files of every indexed directory, including the
+// ones of themes indexed through an icon-theme.cache
+func (c *baseDirIconCache) dump() map[string][]string {
+	directories := make(map[string][]string, len(c.icons))
+	for dir, icons := range c.icons {
+		var files []string
+		for i, iconName := range icons.names {
+			for _, extension := range icons.extensions[i] {
+				files = append(files, iconName+"."+extension)
+			}
+		}
+		directories[dir] = files
+	}
+
+	for theme, gtkCache := range c.gtkCaches {
+		for subdir := range gtkCache.dirs {
+			var files []string
+			for _, extension := range []string{"png", "svg", "xpm"} {
+				for _, iconName := range gtkCache.names(subdir, "", []string{extension}) {
+					files = append(files, iconName+"."+extension)
+				}
+			}
+			slices.Sort(files)
+			directories[theme+"/"+subdir] = files
+		}
+	}
+	return directories
+}
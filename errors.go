@@ -0,0 +1,100 @@
+package xdgicons
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	// Matches (with [errors.Is]) the errors returned when no
+	// icon was found, see [IconNotFoundError].
+	ErrIconNotFound = errors.New("icon not found")
+
+	// Matches (with [errors.Is]) the errors returned when an
+	// icon theme isn't installed, see [ThemeNotFoundError].
+	ErrThemeNotFound = errors.New("theme not found")
+
+	// Matches the errors returned for cache snapshots
+	// that are damaged or weren't written by [IconLookup.SaveCache]
+	ErrCacheCorrupt = errors.New("cache snapshot is corrupt")
+
+	// Matches the errors returned for cache snapshots written
+	// in a format version this version of the package can't read
+	ErrCacheVersion = errors.New("unsupported cache snapshot version")
+
+	// Matches the errors returned for cache snapshots whose
+	// directories changed since they were written
+	ErrCacheStale = errors.New("cache snapshot is stale")
+)
+
+// Returned when no icon was found for the requested names
+type IconNotFoundError struct {
+	// Requested icon names
+	Names []string
+
+	// Themes that were searched, in order
+	Themes []string
+
+	// Icon file extensions that were tried, in order
+	Extensions []string
+}
+
+func (e *IconNotFoundError) Error() string {
+	if len(e.Names) == 1 {
+		return fmt.Sprintf("icon %q not found", e.Names[0])
+	}
+	return fmt.Sprintf("icons \"%s\" not found", strings.Join(e.Names, ","))
+}
+
+func (e *IconNotFoundError) Is(target error) bool {
+	return target == ErrIconNotFound
+}
+
+// Returned when the requested icon theme isn't installed
+type ThemeNotFoundError struct {
+	// Name of the theme
+	Theme string
+
+	// Why the index.theme of the theme couldn't be used,
+	// nil if no base directory has one
+	Err error
+}
+
+func (e *ThemeNotFoundError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("theme %q not found: %v", e.Theme, e.Err)
+	}
+	return fmt.Sprintf("theme %q not found", e.Theme)
+}
+
+func (e *ThemeNotFoundError) Is(target error) bool {
+	return target == ErrThemeNotFound
+}
+
+// describes a failed search for iconNames in the current theme
+// and the fallback theme
+func (il *IconLookup) iconNotFound(iconNames []string, opts LookupOptions) *IconNotFoundError {
+	theme, fallbackTheme := il.Theme(), il.FallbackTheme()
+
+	var themes []string
+	for _, chainTheme := range il.themeChain(theme) {
+		if !slices.Contains(themes, chainTheme) {
+			themes = append(themes, chainTheme)
+		}
+	}
+	if fallbackTheme != "" {
+		for _, chainTheme := range il.themeChain(fallbackTheme) {
+			if !slices.Contains(themes, chainTheme) {
+				themes = append(themes, chainTheme)
+			}
+		}
+	}
+
+	return &IconNotFoundError{
+		Names:      slices.Clone(iconNames),
+		Themes:     themes,
+		Extensions: slices.Clone(opts.Extensions),
+	}
+}
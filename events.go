@@ -0,0 +1,158 @@
+package xdgicons
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Kind of a change reported by [IconLookup.Watch]
+type ChangeKind int
+
+const (
+	// The theme used for lookups was switched, e.g. because
+	// [IconLookup.Reload] picked up a new system theme
+	ThemeChanged ChangeKind = iota
+
+	// Icons or themes were added to or removed from a base directory
+	IconsChanged
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ThemeChanged:
+		return "ThemeChanged"
+	case IconsChanged:
+		return "IconsChanged"
+	}
+	return "ChangeKind(?)"
+}
+
+// A change of the icon set, as reported by [IconLookup.Watch]
+type ChangeEvent struct {
+	Kind ChangeKind
+
+	// The new theme, for ThemeChanged
+	Theme string
+
+	// The base directory that changed, for IconsChanged
+	BaseDir string
+
+	// The indexed themes whose icons changed, for IconsChanged.
+	// Empty if only the base directory itself changed, e.g.
+	// because a theme was installed or removed.
+	Themes []string
+}
+
+type changeSubscriber struct {
+	fn func(ChangeEvent)
+
+	mu      sync.Mutex
+	queue   []ChangeEvent
+	running bool
+	stopped bool
+}
+
+// Calls fn every time the icon set changes, so icons can be resolved
+// again. Events are delivered in order, one at a time, on a goroutine
+// of their own, so fn may do lookups.
+//
+// Changes on disk are noticed when a base directory is revalidated:
+// right away with [LookupConfig.Watch], periodically with
+// [LookupConfig.BackgroundRefresh], otherwise during lookups.
+// The returned function stops the notifications.
+func (il *IconLookup) Watch(fn func(ChangeEvent)) (stop func()) {
+	sub := &changeSubscriber{fn: fn}
+
+	il.subMu.Lock()
+	il.subscribers = append(il.subscribers, sub)
+	il.subMu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			il.subMu.Lock()
+			il.subscribers = slices.DeleteFunc(il.subscribers, func(s *changeSubscriber) bool {
+				return s == sub
+			})
+			il.subMu.Unlock()
+
+			sub.mu.Lock()
+			sub.stopped = true
+			sub.queue = nil
+			sub.mu.Unlock()
+		})
+	}
+}
+
+// hands event to every subscriber, without waiting for them
+func (il *IconLookup) notify(event ChangeEvent) {
+	il.subMu.Lock()
+	subscribers := slices.Clone(il.subscribers)
+	il.subMu.Unlock()
+
+	for _, sub := range subscribers {
+		sub.mu.Lock()
+		if sub.stopped {
+			sub.mu.Unlock()
+			continue
+		}
+		sub.queue = append(sub.queue, event)
+		if !sub.running {
+			sub.running = true
+			go sub.deliver()
+		}
+		sub.mu.Unlock()
+	}
+}
+
+func (s *changeSubscriber) deliver() {
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 || s.stopped {
+			s.running = false
+			s.mu.Unlock()
+			return
+		}
+		event := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		s.fn(event)
+	}
+}
+
+// Compares c to the previous entry of baseDir. Reports whether icons
+// or themes were added or removed since, and in which of the themes
+// indexed by both.
+func (c *baseDirIconCache) changedSince(baseDir string, old *baseDirIconCache) ([]string, bool) {
+	changed := !c.mtime.Equal(old.mtime)
+	themes := make(map[string]bool)
+
+	compare := func(dirPath string) {
+		rel, err := filepath.Rel(baseDir, dirPath)
+		if err != nil {
+			return
+		}
+		theme, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+		if !c.themes[theme] || !old.themes[theme] {
+			// loaded or dropped in between, not changed
+			return
+		}
+		mtime, ok1 := c.dirMtimes[dirPath]
+		oldMtime, ok2 := old.dirMtimes[dirPath]
+		if ok1 != ok2 || !mtime.Equal(oldMtime) {
+			themes[theme] = true
+		}
+	}
+	for dirPath := range c.dirMtimes {
+		compare(dirPath)
+	}
+	for dirPath := range old.dirMtimes {
+		compare(dirPath)
+	}
+
+	return slices.Sorted(maps.Keys(themes)), changed || len(themes) > 0
+}
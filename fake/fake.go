@@ -0,0 +1,108 @@
+// In-memory implementation of xdgicons.Finder, for testing code that
+// resolves icons without depending on the icon themes of the host
+package fake
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/codelif/xdgicons"
+)
+
+// Finder resolves icons from a fixed set added with Add
+type Finder struct {
+	// Size and scale used by Lookup.
+	//
+	// If 0, defaults to 48 and 1
+	DefaultSize  int
+	DefaultScale int
+
+	mu    sync.RWMutex
+	icons map[string][]xdgicons.Icon
+}
+
+var _ xdgicons.Finder = (*Finder)(nil)
+
+// New returns a Finder serving the given icons
+func New(icons ...xdgicons.Icon) *Finder {
+	f := &Finder{}
+	for _, icon := range icons {
+		f.Add(icon)
+	}
+	return f
+}
+
+// Add makes icon available under icon.Name.
+//
+// Several icons can be added under the same name, the one whose
+// Size*Scale is closest to the requested size is returned. Unknown
+// (0) sizes and scales are treated as 48 and 1.
+func (f *Finder) Add(icon xdgicons.Icon) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.icons == nil {
+		f.icons = make(map[string][]xdgicons.Icon)
+	}
+	f.icons[icon.Name] = append(f.icons[icon.Name], icon)
+}
+
+// Remove drops every icon added under name
+func (f *Finder) Remove(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.icons, name)
+}
+
+func (f *Finder) Lookup(iconName string) (xdgicons.Icon, error) {
+	size, scale := f.DefaultSize, f.DefaultScale
+	if size == 0 {
+		size = 48
+	}
+	if scale == 0 {
+		scale = 1
+	}
+	return f.FindIcon(iconName, size, scale)
+}
+
+func (f *Finder) FindIcon(iconName string, size int, scale int) (xdgicons.Icon, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var best xdgicons.Icon
+	bestDistance := -1
+	for _, icon := range f.icons[iconName] {
+		iconSize, iconScale := icon.Size, icon.Scale
+		if iconSize == 0 {
+			iconSize = 48
+		}
+		if iconScale == 0 {
+			iconScale = 1
+		}
+
+		distance := iconSize*iconScale - size*scale
+		if distance < 0 {
+			distance = -distance
+		}
+		if bestDistance < 0 || distance < bestDistance {
+			best = icon
+			bestDistance = distance
+		}
+	}
+
+	if bestDistance < 0 {
+		return xdgicons.Icon{}, &xdgicons.IconNotFoundError{Names: []string{iconName}}
+	}
+	return best, nil
+}
+
+func (f *Finder) FindBestIcon(iconList []string, size int, scale int) (xdgicons.Icon, error) {
+	for _, iconName := range iconList {
+		icon, err := f.FindIcon(iconName, size, scale)
+		if err == nil {
+			return icon, nil
+		}
+	}
+	return xdgicons.Icon{}, &xdgicons.IconNotFoundError{Names: slices.Clone(iconList)}
+}
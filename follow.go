@@ -0,0 +1,194 @@
+package xdgicons
+
+import (
+	"context"
+	"os"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// how often the settings files are checked for changes
+// if the settings portal can't tell about them
+const themePollInterval = 5 * time.Second
+
+type themeFollower struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Switches the theme whenever the system theme changes, as told by the
+// SettingChanged signal of the settings portal, or otherwise by
+// detecting the theme again whenever one of the settings files changed,
+// checked every themePollInterval. Detecting may run dconf and
+// gsettings, so it isn't done on every tick.
+func (il *IconLookup) startFollowingTheme() {
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &themeFollower{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	il.follower = f
+
+	go func() {
+		defer close(f.done)
+
+		if !il.followPortal(ctx) {
+			return
+		}
+
+		ticker := time.NewTicker(themePollInterval)
+		defer ticker.Stop()
+
+		stamps := settingsStamps()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				current := settingsStamps()
+				if slices.EqualFunc(current, stamps, time.Time.Equal) {
+					continue
+				}
+				stamps = current
+				il.switchTheme(il.detectTheme())
+			}
+		}
+	}()
+}
+
+// Lists the modification times of the files desktops keep their icon
+// theme in, zero for missing ones, including the database dconf writes
+// GSettings changes to.
+func settingsStamps() []time.Time {
+	var files []string
+	for _, configDir := range listConfigDirs() {
+		files = append(files,
+			path.Join(configDir, "kdeglobals"),
+			path.Join(configDir, "xfce4", "xfconf", "xfce-perchannel-xml", "xsettings.xml"),
+			path.Join(configDir, "qt5ct", "qt5ct.conf"),
+			path.Join(configDir, "qt6ct", "qt6ct.conf"),
+			path.Join(configDir, "gtk-4.0", "settings.ini"),
+			path.Join(configDir, "gtk-3.0", "settings.ini"),
+			path.Join(configDir, "dconf", "user"),
+		)
+	}
+
+	stamps := make([]time.Time, len(files))
+	for i, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			stamps[i] = info.ModTime()
+		}
+	}
+	return stamps
+}
+
+// Detects the theme again every time the settings portal reports the
+// icon theme changed, until ctx is done. Returns true if the portal
+// can't be followed (anymore), so changes have to be polled for.
+func (il *IconLookup) followPortal(ctx context.Context) bool {
+	conn, err := dbus.SessionBusPrivateNoAutoStartup(dbus.WithContext(ctx))
+	if err != nil {
+		return true
+	}
+	defer conn.Close()
+	if err := conn.Auth(nil); err != nil {
+		return true
+	}
+	if err := conn.Hello(); err != nil {
+		return true
+	}
+
+	err = conn.AddMatchSignalContext(ctx,
+		dbus.WithMatchObjectPath("/org/freedesktop/portal/desktop"),
+		dbus.WithMatchInterface("org.freedesktop.portal.Settings"),
+		dbus.WithMatchMember("SettingChanged"),
+		dbus.WithMatchArg(0, "org.gnome.desktop.interface"),
+		dbus.WithMatchArg(1, "icon-theme"),
+	)
+	if err != nil {
+		return true
+	}
+	if il.colorScheme == SystemColorScheme {
+		err = conn.AddMatchSignalContext(ctx,
+			dbus.WithMatchObjectPath("/org/freedesktop/portal/desktop"),
+			dbus.WithMatchInterface("org.freedesktop.portal.Settings"),
+			dbus.WithMatchMember("SettingChanged"),
+			dbus.WithMatchArg(0, "org.freedesktop.appearance"),
+			dbus.WithMatchArg(1, "color-scheme"),
+		)
+		if err != nil {
+			return true
+		}
+	}
+	signals := make(chan *dbus.Signal, 8)
+	conn.Signal(signals)
+
+	// starts the portal if it isn't running yet, fails if there is none
+	pingCtx, cancel := context.WithTimeout(ctx, portalTimeout)
+	defer cancel()
+	portal := conn.Object("org.freedesktop.portal.Desktop", "/org/freedesktop/portal/desktop")
+	if err := portal.CallWithContext(pingCtx, "org.freedesktop.DBus.Peer.Ping", 0).Err; err != nil {
+		return ctx.Err() == nil
+	}
+
+	// the theme may have changed while subscribing
+	il.switchTheme(il.detectTheme())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case _, ok := <-signals:
+			if !ok {
+				// the bus went away
+				return ctx.Err() == nil
+			}
+			il.switchTheme(il.detectTheme())
+		}
+	}
+}
+
+// Switches to theme if it isn't the current one, indexing it first so
+// lookups don't have to wait for it, and reports it to watchers.
+func (il *IconLookup) switchTheme(theme string) {
+	if theme == "" {
+		return
+	}
+	theme = il.themeVariant(theme)
+	if theme == il.Theme() {
+		return
+	}
+
+	il.mu.Lock()
+	// it may just have been installed
+	delete(il.missingThemes, theme)
+	il.mu.Unlock()
+	_, _ = il.getThemeInfo(theme)
+
+	il.mu.Lock()
+	switched := theme != il.theme
+	il.theme = theme
+	il.mu.Unlock()
+
+	if switched {
+		il.notify(ChangeEvent{Kind: ThemeChanged, Theme: theme})
+	}
+}
+
+// stops following the system theme, if following it,
+// and waits for the goroutine to exit
+func (il *IconLookup) stopFollowingTheme() {
+	il.mu.Lock()
+	f := il.follower
+	il.follower = nil
+	il.mu.Unlock()
+
+	if f == nil {
+		return
+	}
+	f.cancel()
+	<-f.done
+}
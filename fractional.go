@@ -0,0 +1,27 @@
+package xdgicons
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Finds a specified icon for a fractional scale, e.g. 1.5 on
+// fractionally scaled outputs.
+//
+// The icon is searched at the next integer scale, so it only ever has
+// to be scaled down, and [Icon.PixelSize] is set to the size in pixels
+// it should be rendered at.
+func (il *IconLookup) FindIconFractional(iconName string, size int, scale float64) (Icon, error) {
+	if scale <= 0 || math.IsNaN(scale) || math.IsInf(scale, 0) {
+		return Icon{}, fmt.Errorf("invalid scale %v", scale)
+	}
+
+	icon, err := il.findIcon(context.Background(), iconName, size, int(math.Ceil(scale)), il.lookupOptions(LookupOptions{}))
+	if err != nil {
+		return Icon{}, err
+	}
+
+	icon.PixelSize = int(math.Round(float64(size) * scale))
+	return icon, nil
+}
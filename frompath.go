@@ -0,0 +1,56 @@
+package xdgicons
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Reconstructs the metadata of the icon file at iconPath (its theme,
+// subdirectory, size, scale and context) from the index.theme of the
+// theme it is in. Icons placed directly in a base directory are
+// returned with only their name and path set.
+func (il *IconLookup) IconFromPath(iconPath string) (Icon, error) {
+	iconPath = filepath.Clean(iconPath)
+
+	stat, err := os.Stat(iconPath)
+	if err != nil {
+		return Icon{}, err
+	}
+	if stat.IsDir() {
+		return Icon{}, fmt.Errorf("%q is a directory", iconPath)
+	}
+	if !il.pathAllowed(iconPath) {
+		return Icon{}, fmt.Errorf("%q is outside of the allowed roots", iconPath)
+	}
+
+	base := path.Base(iconPath)
+	iconName := strings.TrimSuffix(base, path.Ext(base))
+
+	for _, directory := range il.getBaseDirs() {
+		rel, ok := strings.CutPrefix(iconPath, directory+"/")
+		if !ok {
+			continue
+		}
+
+		theme, rest, ok := strings.Cut(rel, "/")
+		if !ok {
+			return Icon{Name: iconName, Path: iconPath}, nil
+		}
+
+		themeInfo, err := il.getThemeInfo(theme)
+		if err != nil {
+			return Icon{}, err
+		}
+		subdir := path.Dir(rest)
+		if _, ok := themeInfo.directoryMap[subdir]; !ok {
+			return Icon{}, fmt.Errorf("%q is not in a directory of theme %q", iconPath, theme)
+		}
+
+		return themeIcon(themeInfo, theme, subdir, iconName, iconPath), nil
+	}
+
+	return Icon{}, fmt.Errorf("%q is not inside of a base directory", iconPath)
+}
@@ -0,0 +1,47 @@
+package xdgicons
+
+import (
+	"slices"
+	"strings"
+)
+
+// returns the names to search for iconName, in order of preference:
+// its symbolic variants, followed by their generic fallbacks if enabled
+func (il *IconLookup) nameVariants(iconName string) []string {
+	variants := il.symbolicVariants(iconName)
+	if !il.genericFallback {
+		return variants
+	}
+
+	names := slices.Clone(variants)
+	for _, variant := range variants {
+		for _, name := range genericNames(variant) {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Lists the more generic names of iconName as described by the icon
+// naming spec, by dropping one dash-separated segment at a time, e.g.
+// "network-wireless-signal-good" gives "network-wireless-signal",
+// "network-wireless" and "network". The "-symbolic" suffix is kept.
+func genericNames(iconName string) []string {
+	base, isSymbolic := strings.CutSuffix(iconName, symbolicSuffix)
+
+	var names []string
+	for {
+		i := strings.LastIndexByte(base, '-')
+		if i <= 0 {
+			return names
+		}
+		base = base[:i]
+		if isSymbolic {
+			names = append(names, base+symbolicSuffix)
+		} else {
+			names = append(names, base)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package xdgicons
+
+import (
+	"encoding/binary"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+)
+
+// name of the cache files written by gtk-update-icon-cache
+const gtkIconCacheName = "icon-theme.cache"
+
+// image flags of the GTK icon cache format
+const (
+	gtkCacheHasXPM = 1 << iota
+	gtkCacheHasSVG
+	gtkCacheHasPNG
+	gtkCacheHasIconFile
+)
+
+const gtkCacheNone = 0xffffffff
+
+// A memory-mapped icon-theme.cache of a theme directory, as written by
+// gtk-update-icon-cache. It lists every icon of the theme, so the theme
+// doesn't have to be walked to know which files exist.
+type gtkIconCache struct {
+	data []byte
+
+	// position of every directory in the cache's directory list
+	dirs map[string]int
+}
+
+// Opens the icon-theme.cache of themeDir, if there is one that is at
+// least as new as the directory and well-formed. Returns nil otherwise.
+func openGTKIconCache(themeDir string) *gtkIconCache {
+	cachePath := path.Join(themeDir, gtkIconCacheName)
+
+	cacheStat, err := os.Stat(cachePath)
+	if err != nil || cacheStat.Size() < 12 {
+		return nil
+	}
+	dirStat, err := os.Stat(themeDir)
+	if err != nil || cacheStat.ModTime().Before(dirStat.ModTime()) {
+		// icons were added or removed after the cache was written
+		return nil
+	}
+
+	data, release, err := mapFile(cachePath, cacheStat.Size())
+	if err != nil {
+		return nil
+	}
+
+	cache := &gtkIconCache{data: data}
+	if !cache.parseDirectories() {
+		release(data)
+		return nil
+	}
+	runtime.AddCleanup(cache, release, data)
+	return cache
+}
+
+func (c *gtkIconCache) u16(offset uint32) (uint16, bool) {
+	if uint64(offset)+2 > uint64(len(c.data)) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(c.data[offset:]), true
+}
+
+func (c *gtkIconCache) u32(offset uint32) (uint32, bool) {
+	if uint64(offset)+4 > uint64(len(c.data)) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(c.data[offset:]), true
+}
+
+// reads the NUL-terminated string at offset
+func (c *gtkIconCache) str(offset uint32) (string, bool) {
+	if uint64(offset) >= uint64(len(c.data)) {
+		return "", false
+	}
+	end := offset
+	for end < uint32(len(c.data)) && c.data[end] != 0 {
+		end++
+	}
+	if end == uint32(len(c.data)) {
+		return "", false
+	}
+	return string(c.data[offset:end]), true
+}
+
+func (c *gtkIconCache) parseDirectories() bool {
+	major, ok := c.u16(0)
+	if !ok || major != 1 {
+		return false
+	}
+	dirListOffset, ok := c.u32(8)
+	if !ok {
+		return false
+	}
+	nDirs, ok := c.u32(dirListOffset)
+	if !ok || uint64(nDirs)*4 > uint64(len(c.data)) {
+		return false
+	}
+
+	c.dirs = make(map[string]int, nDirs)
+	for i := range nDirs {
+		dirOffset, ok := c.u32(dirListOffset + 4 + 4*i)
+		if !ok {
+			return false
+		}
+		dir, ok := c.str(dirOffset)
+		if !ok {
+			return false
+		}
+		c.dirs[dir] = int(i)
+	}
+	return true
+}
+
+// the hash function of the GTK icon cache, over signed chars
+func gtkCacheHash(name string) uint32 {
+	if name == "" {
+		return 0
+	}
+	h := uint32(int8(name[0]))
+	for i := 1; i < len(name); i++ {
+		h = (h << 5) - h + uint32(int8(name[i]))
+	}
+	return h
+}
+
+// Returns the path of iconName in subdir of the theme with the first
+// of extensions it exists with, skipping paths allowed rejects.
+// Extensions the cache format doesn't record are checked on disk.
+func (c *gtkIconCache) find(themeDir, subdir, iconName string, extensions []string, allowed func(string) bool) (string, bool) {
+	defer runtime.KeepAlive(c)
+
+	flags := c.flags(subdir, iconName)
+	for _, extension := range extensions {
+		iconPath := path.Join(themeDir, subdir, iconName+"."+extension)
+		if flag, ok := gtkCacheFlag(extension); ok {
+			if flags&flag == 0 {
+				continue
+			}
+		} else if _, err := os.Stat(iconPath); err != nil {
+			continue
+		}
+		if allowed(iconPath) {
+			return iconPath, true
+		}
+	}
+	return "", false
+}
+
+// Lists the names starting with prefix of the icons in subdir that
+// exist with any of extensions. Extensions the cache format doesn't
+// record are ignored.
+func (c *gtkIconCache) names(subdir, prefix string, extensions []string) []string {
+	defer runtime.KeepAlive(c)
+
+	var wanted uint16
+	for _, extension := range extensions {
+		flag, _ := gtkCacheFlag(extension)
+		wanted |= flag
+	}
+	dirIndex, ok := c.dirs[subdir]
+	if !ok || wanted == 0 {
+		return nil
+	}
+
+	hashOffset, ok := c.u32(4)
+	if !ok {
+		return nil
+	}
+	nBuckets, ok := c.u32(hashOffset)
+	if !ok || uint64(nBuckets)*4 > uint64(len(c.data)) {
+		return nil
+	}
+
+	var names []string
+	for bucket := range nBuckets {
+		iconOffset, ok := c.u32(hashOffset + 4 + 4*bucket)
+		// bounded, so a corrupt cache with a cyclic chain can't hang
+		for range len(c.data) / 12 {
+			if !ok || iconOffset == gtkCacheNone {
+				break
+			}
+			nameOffset, ok1 := c.u32(iconOffset + 4)
+			name, ok2 := c.str(nameOffset)
+			if !ok1 || !ok2 {
+				break
+			}
+			if strings.HasPrefix(name, prefix) && c.imageFlags(iconOffset, dirIndex)&wanted != 0 {
+				names = append(names, name)
+			}
+			iconOffset, ok = c.u32(iconOffset)
+		}
+	}
+	return names
+}
+
+// returns the image flags of iconName in subdir, 0 if it isn't there
+func (c *gtkIconCache) flags(subdir, iconName string) uint16 {
+	dirIndex, ok := c.dirs[subdir]
+	if !ok {
+		return 0
+	}
+
+	hashOffset, ok := c.u32(4)
+	if !ok {
+		return 0
+	}
+	nBuckets, ok := c.u32(hashOffset)
+	if !ok || nBuckets == 0 {
+		return 0
+	}
+	iconOffset, ok := c.u32(hashOffset + 4 + 4*(gtkCacheHash(iconName)%nBuckets))
+	if !ok {
+		return 0
+	}
+
+	// bounded, so a corrupt cache with a cyclic chain can't hang lookups
+	for range len(c.data) / 12 {
+		if iconOffset == gtkCacheNone {
+			return 0
+		}
+		nameOffset, ok1 := c.u32(iconOffset + 4)
+		name, ok2 := c.str(nameOffset)
+		if !ok1 || !ok2 {
+			return 0
+		}
+		if name == iconName {
+			return c.imageFlags(iconOffset, dirIndex)
+		}
+		iconOffset, ok = c.u32(iconOffset)
+		if !ok {
+			return 0
+		}
+	}
+	return 0
+}
+
+// returns the flags of the image in the directory at dirIndex
+// from the image list of the icon at iconOffset
+func (c *gtkIconCache) imageFlags(iconOffset uint32, dirIndex int) uint16 {
+	listOffset, ok := c.u32(iconOffset + 8)
+	if !ok {
+		return 0
+	}
+	nImages, ok := c.u32(listOffset)
+	if !ok || uint64(nImages)*8 > uint64(len(c.data)) {
+		return 0
+	}
+	for i := range nImages {
+		imageOffset := listOffset + 4 + 8*i
+		index, ok1 := c.u16(imageOffset)
+		flags, ok2 := c.u16(imageOffset + 2)
+		if !ok1 || !ok2 {
+			return 0
+		}
+		if int(index) == dirIndex {
+			return flags
+		}
+	}
+	return 0
+}
+
+// returns the image flag recording extension, if the format has one
+func gtkCacheFlag(extension string) (uint16, bool) {
+	switch extension {
+	case "png":
+		return gtkCacheHasPNG, true
+	case "svg":
+		return gtkCacheHasSVG, true
+	case "xpm":
+		return gtkCacheHasXPM, true
+	}
+	return 0, false
+}
+
+// splits "name.ext" into its parts
+func cutExtension(fileName string) (string, string, bool) {
+	i := strings.LastIndexByte(fileName, '.')
+	if i <= 0 {
+		return "", "", false
+	}
+	return fileName[:i], fileName[i+1:], true
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package xdgicons
+
+import "os"
+
+// reads the file at filePath into memory, as there is no mmap
+func mapFile(filePath string, size int64) ([]byte, func([]byte), error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func([]byte) {}, nil
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package xdgicons
+
+import (
+	"os"
+	"syscall"
+)
+
+// maps the file at filePath into memory, read-only
+func mapFile(filePath string, size int64) ([]byte, func([]byte), error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func(data []byte) { _ = syscall.Munmap(data) }, nil
+}
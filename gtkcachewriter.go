@@ -0,0 +1,207 @@
+package xdgicons
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Writes the icon-theme.cache of themeDir, as gtk-update-icon-cache
+// would, replacing the existing one atomically. The cache is only
+// used (by GTK and by lookups) while it is at least as new as
+// themeDir, so it has to be updated whenever icons are added or removed.
+func UpdateGTKIconCache(themeDir string) error {
+	tmp, err := os.CreateTemp(themeDir, "."+gtkIconCacheName+"-*")
+	if err != nil {
+		return fmt.Errorf("error creating icon cache: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := WriteGTKIconCache(tmp, themeDir); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing icon cache: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing icon cache: %v", err)
+	}
+
+	cachePath := path.Join(themeDir, gtkIconCacheName)
+	if err := os.Rename(tmp.Name(), cachePath); err != nil {
+		return fmt.Errorf("error replacing icon cache: %v", err)
+	}
+
+	// the rename touched themeDir, which must not look newer than the cache
+	dirStat, err := os.Stat(themeDir)
+	if err != nil {
+		return fmt.Errorf("error updating icon cache time: %v", err)
+	}
+	mtime := time.Now()
+	if dirStat.ModTime().After(mtime) {
+		mtime = dirStat.ModTime()
+	}
+	if err := os.Chtimes(cachePath, mtime, mtime); err != nil {
+		return fmt.Errorf("error updating icon cache time: %v", err)
+	}
+	return nil
+}
+
+// Writes an icon-theme.cache for the icons in the directories of
+// themeDir to w, in the format read by GTK. Image data is not embedded.
+func WriteGTKIconCache(w io.Writer, themeDir string) error {
+	// icon name -> directory index -> flags
+	icons := make(map[string]map[int]uint16)
+	var dirs []string
+	dirIndexes := make(map[string]int)
+
+	err := filepath.WalkDir(themeDir, func(subPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if subPath != themeDir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		dir, err := filepath.Rel(themeDir, filepath.Dir(subPath))
+		if err != nil {
+			return err
+		}
+		if dir == "." {
+			// only files in subdirectories are icons
+			return nil
+		}
+
+		iconName, extension, ok := cutExtension(d.Name())
+		if !ok {
+			return nil
+		}
+		flag, ok := gtkCacheFlag(extension)
+		if extension == "icon" {
+			flag, ok = gtkCacheHasIconFile, true
+		}
+		if !ok {
+			return nil
+		}
+
+		dir = filepath.ToSlash(dir)
+		dirIndex, ok := dirIndexes[dir]
+		if !ok {
+			dirIndex = len(dirs)
+			dirIndexes[dir] = dirIndex
+			dirs = append(dirs, dir)
+		}
+		if icons[iconName] == nil {
+			icons[iconName] = make(map[int]uint16)
+		}
+		icons[iconName][dirIndex] |= flag
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking theme directory: %v", err)
+	}
+	if len(dirs) > 0xffff {
+		return fmt.Errorf("too many icon directories: %d", len(dirs))
+	}
+
+	if _, err := w.Write(encodeGTKIconCache(icons, dirs)); err != nil {
+		return fmt.Errorf("error writing icon cache: %v", err)
+	}
+	return nil
+}
+
+// Lays out the cache: header, hash table, the icons with their
+// image lists and names, then the directory list. Every offset
+// is 4 byte aligned.
+func encodeGTKIconCache(icons map[string]map[int]uint16, dirs []string) []byte {
+	nBuckets := gtkCacheBuckets(len(icons))
+	buckets := make([][]string, nBuckets)
+	for iconName := range icons {
+		bucket := gtkCacheHash(iconName) % nBuckets
+		buckets[bucket] = append(buckets[bucket], iconName)
+	}
+
+	data := make([]byte, 12, 64*len(icons)+16*len(dirs)+64)
+	binary.BigEndian.PutUint16(data[0:], 1)
+	binary.BigEndian.PutUint16(data[2:], 0)
+
+	hashOffset := uint32(len(data))
+	binary.BigEndian.PutUint32(data[4:], hashOffset)
+	data = binary.BigEndian.AppendUint32(data, nBuckets)
+	for range nBuckets {
+		data = binary.BigEndian.AppendUint32(data, gtkCacheNone)
+	}
+
+	for bucket, iconNames := range buckets {
+		slices.Sort(iconNames)
+
+		// each icon's offset is stored where the previous one links to
+		link := hashOffset + 4 + 4*uint32(bucket)
+		for _, iconName := range iconNames {
+			iconOffset := uint32(len(data))
+			binary.BigEndian.PutUint32(data[link:], iconOffset)
+			link = iconOffset
+
+			images := icons[iconName]
+			nameOffset := iconOffset + 12
+			listOffset := nameOffset + alignedStringSize(iconName)
+			data = binary.BigEndian.AppendUint32(data, gtkCacheNone)
+			data = binary.BigEndian.AppendUint32(data, nameOffset)
+			data = binary.BigEndian.AppendUint32(data, listOffset)
+			data = appendAlignedString(data, iconName)
+
+			data = binary.BigEndian.AppendUint32(data, uint32(len(images)))
+			for _, dirIndex := range slices.Sorted(maps.Keys(images)) {
+				data = binary.BigEndian.AppendUint16(data, uint16(dirIndex))
+				data = binary.BigEndian.AppendUint16(data, images[dirIndex])
+				// no image data
+				data = binary.BigEndian.AppendUint32(data, 0)
+			}
+		}
+	}
+
+	dirListOffset := uint32(len(data))
+	binary.BigEndian.PutUint32(data[8:], dirListOffset)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(dirs)))
+	dirOffset := dirListOffset + 4 + 4*uint32(len(dirs))
+	for _, dir := range dirs {
+		data = binary.BigEndian.AppendUint32(data, dirOffset)
+		dirOffset += alignedStringSize(dir)
+	}
+	for _, dir := range dirs {
+		data = appendAlignedString(data, dir)
+	}
+
+	return data
+}
+
+// picks an odd bucket count of about a third of the icons, like GTK
+func gtkCacheBuckets(nIcons int) uint32 {
+	return uint32(max(nIcons/3, 1)) | 1
+}
+
+// size of s with its NUL terminator, padded to 4 bytes
+func alignedStringSize(s string) uint32 {
+	return uint32(len(s)+4) &^ 3
+}
+
+func appendAlignedString(data []byte, s string) []byte {
+	data = append(data, s...)
+	for range alignedStringSize(s) - uint32(len(s)) {
+		data = append(data, 0)
+	}
+	return data
+}
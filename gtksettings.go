@@ -0,0 +1,28 @@
+package xdgicons
+
+import (
+	"path"
+
+	"gopkg.in/ini.v1"
+)
+
+// Reads gtk-icon-theme-name from the settings.ini of GTK 4 and 3, the
+// user's first and the system-wide ones after, as written by e.g.
+// lxappearance or nwg-look. Returns "" if none sets it.
+func gtkSettingsIconTheme() string {
+	for _, configDir := range listConfigDirs() {
+		for _, version := range []string{"gtk-4.0", "gtk-3.0"} {
+			cfg, err := ini.LoadSources(ini.LoadOptions{
+				IgnoreInlineComment:     true,
+				SkipUnrecognizableLines: true,
+			}, path.Join(configDir, version, "settings.ini"))
+			if err != nil {
+				continue
+			}
+			if theme := cfg.Section("Settings").Key("gtk-icon-theme-name").String(); theme != "" {
+				return theme
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package xdgicons
+
+import (
+	"context"
+)
+
+// Reports whether [IconLookup.FindIcon] would find iconName at any size,
+// by only consulting the cached directory listings.
+func (il *IconLookup) HasIcon(iconName string) bool {
+	return il.hasIcon(iconName, true, func(ThemeInfo, string) bool {
+		return true
+	})
+}
+
+// Reports whether iconName is available in a directory matching size
+// and scale exactly, like [LookupOptions.ExactSize], by only consulting
+// the cached directory listings.
+func (il *IconLookup) HasIconAt(iconName string, size int, scale int) bool {
+	return il.hasIcon(iconName, false, func(themeInfo ThemeInfo, subdir string) bool {
+		return il.directoryMatchesSize(themeInfo, subdir, size, scale)
+	})
+}
+
+// reports whether one of the variants of iconName is in a theme
+// subdirectory accepted by include, or unthemed if allowed
+func (il *IconLookup) hasIcon(iconName string, unthemed bool, include func(themeInfo ThemeInfo, subdir string) bool) bool {
+	ctx := context.Background()
+	opts := il.lookupOptions(LookupOptions{})
+
+	_, iconName, ok := il.applyOverride(iconName, opts.Extensions)
+	if ok {
+		return true
+	}
+
+	theme, fallbackTheme := il.Theme(), il.FallbackTheme()
+	themes := il.themeChain(theme)
+	if fallbackTheme != "" {
+		themes = append(themes, il.themeChain(fallbackTheme)...)
+	}
+
+	names := il.nameVariants(iconName)
+	for _, chainTheme := range themes {
+		themeInfo, err := il.getThemeInfo(chainTheme)
+		if err != nil {
+			continue
+		}
+		for _, subdir := range themeInfo.allDirectories() {
+			if !il.directoryInContext(themeInfo, subdir) || !include(themeInfo, subdir) {
+				continue
+			}
+			for _, name := range names {
+				if il.themeDirHasIcon(ctx, chainTheme, subdir, name, opts) {
+					return true
+				}
+			}
+		}
+	}
+
+	if !unthemed || il.disablePixmapFallback {
+		return false
+	}
+	for _, directory := range il.getBaseDirs() {
+		for _, name := range names {
+			if _, ok := il.findFile(ctx, directory, "", name, opts.Extensions); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
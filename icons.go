@@ -1,5 +1,7 @@
 package xdgicons
 
+import "slices"
+
 // Found Icon
 type Icon struct {
 	// Short name of the icon
@@ -8,6 +10,23 @@ type Icon struct {
 	// Full path of the icon
 	Path string
 
+	// Theme the icon was found in
+	//
+	// empty, if it isn't part of a theme
+	Theme string
+
+	// Subdirectory of the theme the icon was found in,
+	// e.g. "48x48/apps"
+	//
+	// empty, if it isn't part of a theme
+	Dir string
+
+	// Context of the directory the icon was found in,
+	// e.g. "Applications"
+	//
+	// empty, if unknown
+	Context string
+
 	// Unscaled size of the icon
 	//
 	// set to 0, if unknown
@@ -29,6 +48,11 @@ type Icon struct {
 	//
 	// set to 0, if unknown
 	MaxSize int
+
+	// Size in pixels the icon should be rendered at
+	//
+	// only set by [IconLookup.FindIconFractional]
+	PixelSize int
 }
 
 // Theme info extracted from index.theme
@@ -37,6 +61,18 @@ type ThemeInfo struct {
 	// selecting themes.
 	Name string
 
+	// name of the theme's directory, which identifies
+	// the theme, e.g. in [LookupConfig.Theme]
+	ID string
+
+	// Name translated to the language of LC_MESSAGES,
+	// if the theme provides a translation
+	DisplayName string
+
+	// longer description of the theme, translated to the
+	// language of LC_MESSAGES if the theme provides a translation
+	Comment string
+
 	// The name of the theme that this theme inherits from.
 	// If an icon name is not found in the current theme,
 	// it is searched for in the inherited theme
@@ -65,8 +101,47 @@ type ThemeInfo struct {
 	// implementations that don't support these.
 	ScaledDirectories []string
 
+	// name of an icon representing the theme, e.g. in
+	// theme selection dialogs. See [IconLookup.ExampleIcon]
+	Example string
+
+	// whether the theme should be left out of theme selection
+	// dialogs, e.g. because it's only meant to be inherited from.
+	// Hidden themes aren't listed by [IconLookup.ListThemes]
+	Hidden bool
+
+	// Non-standard constructs found in index.theme that were
+	// normalized instead of failing, e.g. size keys like "16x16"
+	// or directories listed with trailing slashes
+	Warnings []string
+
 	// map to each info of every subdirectory
 	directoryMap map[string]SubDirIconInfo
+
+	// themes added to Inherits that index.theme doesn't list
+	implicitInherits []string
+
+	// base directories the index.theme files were read
+	// from, in the order they were merged
+	baseDirs []string
+
+	// the localized keys as read from index.theme, nil if
+	// the ThemeInfo wasn't read
+	localized *themeStrings
+}
+
+// Name and Comment of an index.theme with their translations,
+// so [WriteThemeIndex] writes them back instead of the values
+// for the current locale
+type themeStrings struct {
+	name    string
+	comment string
+
+	// Comment for the locale it was read in
+	localizedComment string
+
+	// e.g. "Comment[de]" -> its value
+	translations map[string]string
 }
 
 // Common properties of icons listed under a sub-directory
@@ -108,4 +183,64 @@ type SubDirIconInfo struct {
 	//
 	// Defaults to 2 if not present.
 	Threshold int
+
+	// The context the icons in this directory belong to,
+	// e.g. "Applications" or "MimeTypes".
+	//
+	// Empty if not present.
+	Context string
+}
+
+// Returns the properties of subdir, one of Directories or
+// ScaledDirectories, as described by its section in index.theme
+func (t ThemeInfo) Directory(subdir string) (SubDirIconInfo, bool) {
+	info, ok := t.directoryMap[subdir]
+	return info, ok
 }
+
+// Adds the directories of other, read from a later base directory,
+// that t lacks. Directories in both keep the properties from t.
+func (t *ThemeInfo) merge(other *ThemeInfo) {
+	for _, dir := range other.Directories {
+		if _, ok := t.directoryMap[dir]; !ok {
+			t.Directories = append(t.Directories, dir)
+			t.directoryMap[dir] = other.directoryMap[dir]
+		}
+	}
+	for _, dir := range other.ScaledDirectories {
+		if _, ok := t.directoryMap[dir]; !ok {
+			t.ScaledDirectories = append(t.ScaledDirectories, dir)
+			t.directoryMap[dir] = other.directoryMap[dir]
+		}
+	}
+	for _, warning := range other.Warnings {
+		if !slices.Contains(t.Warnings, warning) {
+			t.Warnings = append(t.Warnings, warning)
+		}
+	}
+	t.baseDirs = append(t.baseDirs, other.baseDirs...)
+}
+
+// returns Directories followed by ScaledDirectories, in a new slice
+// so callers can't write into the backing array of the cached lists
+func (t ThemeInfo) allDirectories() []string {
+	return slices.Concat(t.Directories, t.ScaledDirectories)
+}
+
+// Icon lookups as provided by [IconLookup].
+//
+// Code that only resolves icons can depend on this instead of the
+// concrete type, and use the in-memory implementation in
+// xdgicons/fake in its tests.
+type Finder interface {
+	// Finds a specified icon with the default size and scale
+	Lookup(iconName string) (Icon, error)
+
+	// Finds a specified icon with required size and scale
+	FindIcon(iconName string, size int, scale int) (Icon, error)
+
+	// Finds the first available icon in iconList with the required size and scale
+	FindBestIcon(iconList []string, size int, scale int) (Icon, error)
+}
+
+var _ Finder = (*IconLookup)(nil)
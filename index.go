@@ -0,0 +1,220 @@
+package xdgicons
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// Icon files of a base directory, by directory relative to the base
+// directory (e.g. "hicolor/48x48/apps", or "" for the files directly
+// in it).
+//
+// Lookups need a single map access and binary search per directory
+// and icon name, no matter how many extensions they accept.
+type iconIndex map[string]*dirIcons
+
+// The icons of a single directory, sorted by name, so names can be
+// binary searched and names with a common prefix are next to each
+// other. Immutable once sorted, so entries can share them.
+type dirIcons struct {
+	names []string
+
+	// the extensions names[i] exists with
+	extensions [][]string
+}
+
+// extension lists shared by every icon that only exists with one
+// of the common extensions, clipped so appending copies them
+var singleExtensions = map[string][]string{
+	"png":  slices.Clip([]string{"png"}),
+	"svg":  slices.Clip([]string{"svg"}),
+	"xpm":  slices.Clip([]string{"xpm"}),
+	"icon": slices.Clip([]string{"icon"}),
+}
+
+// Adds the file at rel, relative to the base directory. Files without
+// an extension can't be icons and are skipped. The index has to be
+// sorted before it is used.
+func (idx iconIndex) add(rel string) {
+	dir, fileName := path.Split(rel)
+	iconName, extension, ok := cutExtension(fileName)
+	if !ok {
+		return
+	}
+	dir = strings.TrimSuffix(dir, "/")
+
+	icons := idx[dir]
+	if icons == nil {
+		icons = &dirIcons{}
+		// substrings would keep the whole path alive
+		idx[strings.Clone(dir)] = icons
+	}
+	extensions, ok := singleExtensions[extension]
+	if !ok {
+		extensions = []string{strings.Clone(extension)}
+	}
+	icons.names = append(icons.names, strings.Clone(iconName))
+	icons.extensions = append(icons.extensions, extensions)
+}
+
+// sorts every directory of the index
+func (idx iconIndex) sort() {
+	for _, icons := range idx {
+		icons.sort()
+	}
+}
+
+// returns the number of indexed files
+func (idx iconIndex) count() int {
+	n := 0
+	for _, icons := range idx {
+		for _, extensions := range icons.extensions {
+			n += len(extensions)
+		}
+	}
+	return n
+}
+
+// Sorts the names, merging the extensions of names added more than
+// once. Directories that are sorted already are left untouched, so
+// this is safe on directories shared with other entries.
+func (d *dirIcons) sort() {
+	if slices.IsSortedFunc(d.names, strings.Compare) && !hasAdjacentDuplicates(d.names) {
+		return
+	}
+
+	order := make([]int, len(d.names))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return strings.Compare(d.names[a], d.names[b])
+	})
+
+	names := make([]string, 0, len(order))
+	extensions := make([][]string, 0, len(order))
+	for _, i := range order {
+		if n := len(names); n > 0 && names[n-1] == d.names[i] {
+			extensions[n-1] = append(extensions[n-1], d.extensions[i]...)
+			continue
+		}
+		names = append(names, d.names[i])
+		extensions = append(extensions, d.extensions[i])
+	}
+	d.names = slices.Clip(names)
+	d.extensions = slices.Clip(extensions)
+}
+
+func hasAdjacentDuplicates(names []string) bool {
+	for i := 1; i < len(names); i++ {
+		if names[i] == names[i-1] {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the extensions iconName exists with, nil if it doesn't
+func (d *dirIcons) lookup(iconName string) []string {
+	if d == nil {
+		return nil
+	}
+	i, ok := slices.BinarySearch(d.names, iconName)
+	if !ok {
+		return nil
+	}
+	return d.extensions[i]
+}
+
+// returns the range of names starting with prefix
+func (d *dirIcons) prefixed(prefix string) (int, int) {
+	if d == nil {
+		return 0, 0
+	}
+	start, _ := slices.BinarySearch(d.names, prefix)
+	end := start
+	for end < len(d.names) && strings.HasPrefix(d.names[end], prefix) {
+		end++
+	}
+	return start, end
+}
+
+// reports whether dir, relative to the base directory, belongs to theme
+func dirInTheme(dir, theme string) bool {
+	return dir == theme || strings.HasPrefix(dir, theme+"/")
+}
+
+// Returns the path of iconName in dir (relative to baseDir) with the
+// first of extensions it exists with, skipping paths allowed rejects.
+func (c *baseDirIconCache) find(baseDir, dir, iconName string, extensions []string, allowed func(string) bool) (string, bool) {
+	theme, subdir, _ := strings.Cut(dir, "/")
+	if gtkCache := c.gtkCaches[theme]; gtkCache != nil {
+		return gtkCache.find(path.Join(baseDir, theme), subdir, iconName, extensions, allowed)
+	}
+
+	available := c.icons[dir].lookup(iconName)
+	if available == nil {
+		return "", false
+	}
+	for _, extension := range extensions {
+		if !slices.Contains(available, extension) {
+			continue
+		}
+		iconPath := path.Join(baseDir, dir, iconName+"."+extension)
+		if allowed(iconPath) {
+			return iconPath, true
+		}
+	}
+	return "", false
+}
+
+// Lists the names starting with prefix of the icons in dir
+// (relative to baseDir) that exist with any of extensions.
+func (c *baseDirIconCache) names(dir, prefix string, extensions []string) []string {
+	theme, subdir, _ := strings.Cut(dir, "/")
+	if gtkCache := c.gtkCaches[theme]; gtkCache != nil {
+		return gtkCache.names(subdir, prefix, extensions)
+	}
+
+	icons := c.icons[dir]
+	start, end := icons.prefixed(prefix)
+
+	var names []string
+	for i := start; i < end; i++ {
+		if slices.ContainsFunc(icons.extensions[i], func(extension string) bool {
+			return slices.Contains(extensions, extension)
+		}) {
+			names = append(names, icons.names[i])
+		}
+	}
+	return names
+}
+
+// converts the index to plain maps, e.g. to persist it
+func (idx iconIndex) export() map[string]map[string][]string {
+	exported := make(map[string]map[string][]string, len(idx))
+	for dir, icons := range idx {
+		names := make(map[string][]string, len(icons.names))
+		for i, iconName := range icons.names {
+			names[iconName] = icons.extensions[i]
+		}
+		exported[dir] = names
+	}
+	return exported
+}
+
+// builds an index from the maps returned by export
+func importIndex(exported map[string]map[string][]string) iconIndex {
+	idx := make(iconIndex, len(exported))
+	for dir, names := range exported {
+		icons := &dirIcons{}
+		for iconName, extensions := range names {
+			icons.names = append(icons.names, iconName)
+			icons.extensions = append(icons.extensions, slices.Clip(extensions))
+		}
+		icons.sort()
+		idx[dir] = icons
+	}
+	return idx
+}
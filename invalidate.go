@@ -0,0 +1,53 @@
+package xdgicons
+
+import "context"
+
+// Rescans every base directory right away, e.g. after the application
+// was told that a theme was installed, instead of waiting for the
+// cache to be revalidated.
+//
+// Lookups keep using the previous cache until the rescan is done.
+func (il *IconLookup) Refresh() {
+	il.cacheBaseDirectories(context.Background(), il.getBaseDirs())
+
+	// themes that were missing so far may have been installed
+	il.mu.Lock()
+	il.missingThemes = make(map[string]bool)
+	il.mu.Unlock()
+}
+
+// Drops the parsed index.theme of theme and indexes its
+// files again, e.g. after the theme was updated.
+func (il *IconLookup) InvalidateTheme(theme string) {
+	il.mu.Lock()
+	delete(il.themeInfoCache, theme)
+	delete(il.missingThemes, theme)
+	delete(il.loadedThemes, theme)
+	// an index.theme parsed right now may already be outdated
+	il.themeGeneration++
+	il.mu.Unlock()
+
+	il.loadTheme(theme, false)
+}
+
+// Drops all cached directory and theme data. Unlike [IconLookup.Reload],
+// nothing is rescanned right away, base directories and themes are
+// indexed again when lookups need them.
+func (il *IconLookup) InvalidateAll() {
+	il.mu.Lock()
+	defer il.mu.Unlock()
+
+	il.dirCache = make(map[string]*baseDirIconCache)
+	il.dirGeneration++
+	il.clearThemeInfoCache()
+	il.missingThemes = make(map[string]bool)
+	il.loadedThemes = make(map[string]bool)
+
+	// rebuilt on demand, even if watched or never rechecked
+	il.evictedDirs = make(map[string]bool)
+	il.invalidatedDirs = make(map[string]bool)
+	il.missingDirs = make(map[string]missingDir)
+	for _, baseDir := range il.baseDirs {
+		il.invalidatedDirs[baseDir] = true
+	}
+}
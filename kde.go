@@ -0,0 +1,28 @@
+package xdgicons
+
+import (
+	"path"
+
+	"gopkg.in/ini.v1"
+)
+
+// icon theme Plasma uses if kdeglobals doesn't set one
+const kdeDefaultTheme = "breeze"
+
+// Reads the Theme key of the [Icons] group of kdeglobals, from the
+// user's configuration first and the system-wide ones after.
+func kdeIconTheme() string {
+	for _, configDir := range listConfigDirs() {
+		cfg, err := ini.LoadSources(ini.LoadOptions{
+			IgnoreInlineComment:     true,
+			SkipUnrecognizableLines: true,
+		}, path.Join(configDir, "kdeglobals"))
+		if err != nil {
+			continue
+		}
+		if theme := cfg.Section("Icons").Key("Theme").String(); theme != "" {
+			return theme
+		}
+	}
+	return kdeDefaultTheme
+}
@@ -0,0 +1,104 @@
+package xdgicons
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Lists the names of the icons in theme, in any of its directories
+// and base directories, sorted. Icons of the themes it inherits
+// from aren't included. Only files with one of the configured
+// extensions count.
+func (il *IconLookup) ListIcons(theme string) ([]string, error) {
+	ctx := context.Background()
+	themeInfo, err := il.getThemeInfo(theme)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, directory := range il.getBaseDirs() {
+		cacheEntry := il.cacheEntry(ctx, directory)
+		if cacheEntry == nil {
+			continue
+		}
+		for _, subdir := range themeInfo.allDirectories() {
+			for _, iconName := range cacheEntry.names(path.Join(theme, subdir), "", il.extensions) {
+				names[iconName] = true
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(names)), nil
+}
+
+// Lists the icon themes installed in any of the base directories,
+// sorted by ID, for e.g. theme selection dialogs. Directories without
+// a valid index.theme, like the ones of cursor themes, are left out,
+// and so are hidden themes (see [ThemeInfo.Hidden]), which can still
+// be inspected with [IconLookup.ThemeInfo]. Unlike lookups, listing
+// themes doesn't index their icons.
+//
+// Returns an error only if none of the base directories can be read.
+func (il *IconLookup) ListThemes() ([]ThemeInfo, error) {
+	ctx := context.Background()
+
+	ids := make(map[string]bool)
+	var read bool
+	var readErr error
+	for _, directory := range il.getBaseDirs() {
+		if il.degraded(directory) || !il.pathAllowed(directory) {
+			continue
+		}
+
+		var entries []os.DirEntry
+		var err error
+		inTime := il.guard(ctx, directory, func() {
+			entries, err = os.ReadDir(directory)
+			// drop what's not a theme while still guarded,
+			// as symlinks have to be resolved
+			entries = slices.DeleteFunc(entries, func(entry os.DirEntry) bool {
+				return strings.HasPrefix(entry.Name(), ".") || !isDirEntry(directory, entry)
+			})
+		}, nil)
+		if !inTime {
+			continue
+		}
+		if err != nil {
+			readErr = err
+			continue
+		}
+		read = true
+		for _, entry := range entries {
+			ids[entry.Name()] = true
+		}
+	}
+	if !read && readErr != nil {
+		return nil, fmt.Errorf("error listing themes: %v", readErr)
+	}
+
+	var themes []ThemeInfo
+	for _, id := range slices.Sorted(maps.Keys(ids)) {
+		themeInfo, err := il.readThemeInfo(id)
+		if err != nil || themeInfo.Hidden {
+			continue
+		}
+		themes = append(themes, themeInfo)
+	}
+	return themes, nil
+}
+
+// Finds the example icon of a theme listed by [IconLookup.ListThemes],
+// searched for in that theme and the ones it inherits from, instead
+// of the theme of the IconLookup. Returns an error matching
+// [ErrIconNotFound] if the theme has no example icon.
+func (il *IconLookup) ExampleIcon(themeInfo ThemeInfo, size int, scale int) (Icon, error) {
+	if themeInfo.Example == "" {
+		return Icon{}, fmt.Errorf("%w: theme %s has no example icon", ErrIconNotFound, themeInfo.ID)
+	}
+	return il.findIconHelper(context.Background(), themeInfo.Example, size, scale, themeInfo.ID, il.lookupOptions(LookupOptions{}))
+}
@@ -0,0 +1,55 @@
+package xdgicons
+
+import (
+	"os"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Returns the locale suffixes of keys like Name[de_DE] to look for,
+// best match first, for the locale messages are shown in. For
+// lang_COUNTRY.ENCODING@MODIFIER these are lang_COUNTRY@MODIFIER,
+// lang_COUNTRY, lang@MODIFIER and lang, as per the desktop entry spec.
+func messageLocales() []string {
+	locale := os.Getenv("LC_ALL")
+	if locale == "" {
+		locale = os.Getenv("LC_MESSAGES")
+	}
+	if locale == "" {
+		locale = os.Getenv("LANG")
+	}
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return nil
+	}
+
+	locale, modifier, _ := strings.Cut(locale, "@")
+	locale, _, _ = strings.Cut(locale, ".")
+	lang, country, _ := strings.Cut(locale, "_")
+
+	var locales []string
+	if country != "" && modifier != "" {
+		locales = append(locales, lang+"_"+country+"@"+modifier)
+	}
+	if country != "" {
+		locales = append(locales, lang+"_"+country)
+	}
+	if modifier != "" {
+		locales = append(locales, lang+"@"+modifier)
+	}
+	return append(locales, lang)
+}
+
+// returns the value of key translated to the best match of locales,
+// or the untranslated one, "" if neither is set
+func localizedValue(section *ini.Section, key string, locales []string) string {
+	for _, locale := range locales {
+		if k, err := section.GetKey(key + "[" + locale + "]"); err == nil {
+			return k.String()
+		}
+	}
+	if k, err := section.GetKey(key); err == nil {
+		return k.String()
+	}
+	return ""
+}
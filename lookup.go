@@ -1,23 +1,79 @@
 package xdgicons
 
 import (
-	"fmt"
-	"math"
+	"context"
+	"maps"
 	"path"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Looks up icons following the XDG icon theme spec.
+//
+// An IconLookup is safe for concurrent use by multiple goroutines.
+// Lookups only ever take a read lock; directory walks and index.theme
+// parsing run without holding any lock and the results are swapped in
+// afterwards, so a slow rescan never blocks lookups using the previous
+// data. Concurrent lookups needing the same rescan share a single walk.
 type IconLookup struct {
 	theme                   string
+	explicitTheme           bool
 	fallbackTheme           string
 	extensions              []string
+	allowedRoots            []string
+	customBaseDirs          []string
+	additionalBaseDirs      []string
+	baseDirs                []string
+	baseDirInfos            []BaseDirInfo
 	themeInfoCache          map[string]ThemeInfo
+	missingThemes           map[string]bool
+	themeLastUsed           map[string]time.Time
+	loadedThemes            map[string]bool
+	loadGeneration          uint64
 	dirCache                map[string]*baseDirIconCache
+	scans                   map[string]chan struct{}
+	dirGeneration           uint64
+	themeGeneration         uint64
 	cacheValidCheckInterval time.Duration
+	checkIntervals          map[string]time.Duration
+	rescanDebounce          time.Duration
+	minRescanInterval       time.Duration
+	rescanJitter            time.Duration
+	maxCachedFiles          int
+	shareCache              bool
+	evictedDirs             map[string]bool
+	invalidatedDirs         map[string]bool
+	missingDirs             map[string]missingDir
+	degradedDirs            map[string]int
+	scanTimeout             time.Duration
+	evictions               uint64
+	cacheHits               atomic.Uint64
+	cacheMisses             atomic.Uint64
 	defaultSize             int
 	defaultScale            int
+	preferSymbolic          bool
+	preferFullColor         bool
+	symbolicFallback        bool
+	fullColorFallback       bool
+	genericFallback         bool
+	disablePixmapFallback   bool
+	preferScalable          bool
+	baseDirPriority         BaseDirPriority
+	iconContext             string
+	onThemeWarning          func(theme, warning string)
+	overrides               map[string]string
+	watcher                 *dirWatcher
+	refresher               *backgroundRefresher
+	xsettings               *xsettingsClient
+	follower                *themeFollower
+	colorScheme             ColorScheme
+	implicitInherits        []string
+	strictThemeIndex        bool
+	subscribers             []*changeSubscriber
+	subMu                   sync.Mutex
 	mu                      sync.RWMutex
 }
 
@@ -25,19 +81,63 @@ func NewIconLookup() *IconLookup {
 	return NewIconLookupWithConfig(LookupConfig{})
 }
 
+// Value of [LookupConfig.CacheCheckInterval] that disables rechecking
+const NeverRecheck time.Duration = -1
+
 type LookupConfig struct {
 	// Icon Theme to use.
 	//
 	// If unset, then uses default theme
 	Theme string
 
+	// Follow the icon theme broadcast by the XSETTINGS manager of
+	// the X display (e.g. xsettingsd), as GTK2 and other X11 toolkits
+	// do, if Theme is unset. The theme is switched whenever the
+	// manager changes it, reported as ThemeChanged by
+	// [IconLookup.Watch]. Call [IconLookup.Close] to stop following it.
+	//
+	// If there's no display or no manager broadcasting an icon
+	// theme, the default theme is used as if unset
+	XSettings bool
+
+	// Switch the theme whenever the system theme changes, if Theme
+	// is unset, reported as ThemeChanged by [IconLookup.Watch].
+	// Changes are told by the settings portal. If there's no portal,
+	// the theme is detected again whenever the settings files of the
+	// desktops or the dconf database change, checked every few
+	// seconds. The new theme is
+	// indexed before it's switched to. Call [IconLookup.Close] to
+	// stop following it.
+	FollowSystemTheme bool
+
+	// Use the dark or light variant of the theme, e.g. Papirus-Dark
+	// or breeze-dark, so icons match the appearance of the desktop.
+	// Themes without such a variant are used as is, and variants
+	// without the opposite one fall back to their base theme.
+	// With FollowSystemTheme, SystemColorScheme is followed as well.
+	//
+	// If unset, the theme is used as is
+	ColorScheme ColorScheme
+
+	// Themes inserted right before hicolor into the inheritance of
+	// every theme, as the icon theme spec allows, so icons a theme
+	// lacks are taken from the desktop's own theme instead of hicolor.
+	// Themes that aren't installed are skipped.
+	//
+	// If unset (nil), depends on XDG_CURRENT_DESKTOP: Adwaita on GNOME,
+	// breeze on KDE and elementary on Pantheon. An empty slice
+	// disables it, so themes only ever fall back to hicolor
+	ImplicitInherits []string
+
 	// Fallback Theme to look into,
 	// if no icon was found using the canonical algorithm
 	//
 	// If unset, does not search for a fallback theme
 	FallbackTheme string
 
-	// Icon file extensions to search for.
+	// Icon file extensions to search for. "jpg", "jpeg", "gif" and "webp",
+	// which aren't part of the spec but which some pixmaps and third-party
+	// icons come as, can be added too; the render package decodes them.
 	//
 	// If unset, defaults to ["png", "svg", "xpm"]
 	Extensions []string
@@ -51,19 +151,205 @@ type LookupConfig struct {
 	//
 	// If unset or 0, defaults to 1
 	DefaultScale int
+
+	// Search for the "-symbolic" variant of requested icons
+	// first, and for the requested name if it isn't found.
+	//
+	// Takes precedence over PreferFullColor
+	PreferSymbolic bool
+
+	// Search for the full-color variant of requested "-symbolic"
+	// icons first, and for the requested name if it isn't found.
+	PreferFullColor bool
+
+	// Search for the full-color variant of requested "-symbolic"
+	// icons if they aren't found, like GTK does.
+	SymbolicFallback bool
+
+	// Search for the "-symbolic" variant of requested full-color
+	// icons if they aren't found.
+	FullColorFallback bool
+
+	// Search for more generic names of requested icons if they
+	// aren't found, as described by the icon naming spec, e.g.
+	// "network-wireless-signal-good" falls back to
+	// "network-wireless-signal", "network-wireless" and "network".
+	GenericFallback bool
+
+	// Watch the base directories and the theme directories in them
+	// for changes, so newly installed themes are picked up right away.
+	// Call [IconLookup.Close] to stop watching.
+	//
+	// Directories further down aren't watched, since a watch for each
+	// would exhaust the inotify watch limit, so changes to icons are
+	// still found by polling mtimes. If watching fails to set up, the
+	// lookup works as if unset
+	Watch bool
+
+	// How often the cached listing of a base directory is checked
+	// for changes (by comparing the mtimes of the base directory and
+	// the indexed theme directories).
+	//
+	// If unset or 0, defaults to 5 seconds. NeverRecheck (or any
+	// negative value) disables rechecking, so only [IconLookup.Reload]
+	// and watching pick up changes
+	CacheCheckInterval time.Duration
+
+	// CacheCheckInterval of individual base directories, keyed by
+	// their path, e.g. NeverRecheck for /usr/share/icons, which only
+	// changes when packages are installed, and a second for
+	// ~/.local/share/icons while working on a theme.
+	//
+	// Base directories that aren't listed, or are mapped to 0,
+	// use CacheCheckInterval
+	BaseDirCheckIntervals map[string]time.Duration
+
+	// Revalidate the base directories in a background goroutine, each
+	// at its check interval, instead of during lookups, so lookups
+	// never pay for a rescan. Call [IconLookup.Close] to stop it.
+	//
+	// Has no effect if no base directory is ever rechecked
+	BackgroundRefresh bool
+
+	// Time to wait for changes noticed while watching to settle,
+	// before the base directory is rescanned. Every further change
+	// within that time restarts the wait.
+	//
+	// If unset or 0, defaults to 500ms
+	RescanDebounce time.Duration
+
+	// Minimum time between two rescans of the same base directory,
+	// so heavy filesystem churn (e.g. package upgrades) doesn't cause
+	// one full rescan after another.
+	//
+	// If unset or 0, defaults to 2 seconds
+	MinRescanInterval time.Duration
+
+	// Upper bound of a random delay added to rescans and cache
+	// revalidations, so several processes noticing the same change
+	// don't all rescan at the same time.
+	//
+	// If unset or 0, defaults to 1 second. Negative values disable jitter
+	RescanJitter time.Duration
+
+	// Maximum time to wait for a base directory to be scanned or
+	// checked for changes. Base directories taking longer, e.g. a hung
+	// NFS or FUSE mount, are marked degraded and skipped by lookups
+	// until the stuck operation returns, and are brought up to date
+	// in the background then.
+	//
+	// If unset or 0, defaults to 10 seconds. Negative values disable the timeout
+	ScanTimeout time.Duration
+
+	// Maximum number of files kept in the directory cache. Once
+	// exceeded, the least recently used base directories are dropped
+	// from the cache and indexed again when they are needed.
+	// Base directories used within the last second are kept, so
+	// the limit may be exceeded for a short time. Themes indexed
+	// through an icon-theme.cache don't count.
+	//
+	// If unset or 0, the cache is unbounded
+	MaxCachedFiles int
+
+	// Share the directory index with every other lookup that sets
+	// ShareCache, e.g. one per plugin of a panel, so directories are
+	// scanned once and their index is held once per process, instead
+	// of once per lookup. Lookups with different base directories or
+	// themes can share it as well. Ignored with AllowedRoots.
+	ShareCache bool
+
+	// Icons to use instead of the themed ones, consulted before
+	// the normal lookup. Maps an icon name either to another icon
+	// name to search for, or to the absolute path of an icon file.
+	//
+	// Overrides are not applied recursively. Absolute paths that
+	// don't exist, or lack one of the icon extensions, are ignored
+	Overrides map[string]string
+
+	// Prefer SVGs in Scalable directories of the requested scale
+	// over raster icons of the exact size, for callers rendering
+	// icons themselves (e.g. on HiDPI outputs).
+	PreferScalable bool
+
+	// Don't fall back to unthemed icons placed directly in the
+	// base directories (e.g. /usr/share/pixmaps), which are often
+	// badly sized, when an icon isn't found in the theme.
+	DisablePixmapFallback bool
+
+	// Only return icons from theme directories of this context,
+	// e.g. "Applications" or "MimeTypes", compared case-insensitively.
+	// Icons outside of themes (e.g. in pixmaps) have no context and
+	// are not affected.
+	//
+	// If unset, icons of every context are returned
+	Context string
+
+	// Called for every non-standard construct found while parsing
+	// an index.theme, e.g. size keys like "16x16", which was
+	// normalized instead of failing. See [ThemeInfo.Warnings].
+	//
+	// If unset, warnings are only recorded in ThemeInfo
+	OnThemeWarning func(theme, warning string)
+
+	// Refuse to use themes whose index.theme has any problems,
+	// e.g. for checking themes while working on them. The error
+	// of [IconLookup.ThemeInfo] lists all of them.
+	//
+	// If unset, problems are worked around where possible and
+	// reported as warnings: non-standard values are normalized and
+	// directories with a broken section (e.g. without Size) are
+	// skipped, instead of failing the whole theme
+	StrictThemeIndex bool
+
+	// Base directories to search instead of the ones from the
+	// environment (see [GetBaseDirs]), in order. They are not
+	// affected by [IconLookup.ReloadEnvironment].
+	//
+	// If unset, the base directories are taken from the environment
+	BaseDirs []string
+
+	// Base directories to search after the ones from the
+	// environment (but before /usr/share/pixmaps), in order.
+	// Ignored if BaseDirs is set.
+	AdditionalBaseDirs []string
+
+	// Which base directories win when a theme is installed
+	// in several of them.
+	//
+	// If unset, defaults to UserFirst
+	BaseDirPriority BaseDirPriority
+
+	// Directories all filesystem access is restricted to.
+	// Base directories outside of them are ignored, and so are
+	// files and index.theme files whose symlinks resolve outside
+	// of them.
+	//
+	// If unset, there are no restrictions
+	AllowedRoots []string
 }
 
 func NewIconLookupWithConfig(cfg LookupConfig) *IconLookup {
 	il := &IconLookup{
-		themeInfoCache:          make(map[string]ThemeInfo),
-		dirCache:                make(map[string]*baseDirIconCache),
-		cacheValidCheckInterval: 5 * time.Second,
+		themeInfoCache:  make(map[string]ThemeInfo),
+		missingThemes:   make(map[string]bool),
+		themeLastUsed:   make(map[string]time.Time),
+		loadedThemes:    make(map[string]bool),
+		evictedDirs:     make(map[string]bool),
+		invalidatedDirs: make(map[string]bool),
+		missingDirs:     make(map[string]missingDir),
+		degradedDirs:    make(map[string]int),
+		dirCache:        make(map[string]*baseDirIconCache),
+		scans:           make(map[string]chan struct{}),
 	}
 
 	if cfg.Theme == "" {
-		il.theme = DefaultTheme()
+		if cfg.XSettings {
+			il.xsettings, _ = connectXSettings()
+		}
+		il.theme = il.detectTheme()
 	} else {
 		il.theme = cfg.Theme
+		il.explicitTheme = true
 	}
 
 	il.fallbackTheme = cfg.FallbackTheme
@@ -86,25 +372,142 @@ func NewIconLookupWithConfig(cfg LookupConfig) *IconLookup {
 		il.defaultScale = cfg.DefaultScale
 	}
 
+	il.cacheValidCheckInterval = cfg.CacheCheckInterval
+	if il.cacheValidCheckInterval == 0 {
+		il.cacheValidCheckInterval = 5 * time.Second
+	}
+	il.checkIntervals = make(map[string]time.Duration)
+	for dirPath, interval := range cfg.BaseDirCheckIntervals {
+		if interval != 0 {
+			il.checkIntervals[path.Clean(dirPath)] = interval
+		}
+	}
+
+	il.rescanDebounce = cfg.RescanDebounce
+	if il.rescanDebounce == 0 {
+		il.rescanDebounce = 500 * time.Millisecond
+	}
+
+	il.minRescanInterval = cfg.MinRescanInterval
+	if il.minRescanInterval == 0 {
+		il.minRescanInterval = 2 * time.Second
+	}
+
+	il.rescanJitter = cfg.RescanJitter
+	if il.rescanJitter == 0 {
+		il.rescanJitter = time.Second
+	}
+
+	il.scanTimeout = cfg.ScanTimeout
+	if il.scanTimeout == 0 {
+		il.scanTimeout = 10 * time.Second
+	}
+
+	il.maxCachedFiles = cfg.MaxCachedFiles
+	// restricted lookups index less, and must not reuse what others indexed
+	il.shareCache = cfg.ShareCache && cfg.AllowedRoots == nil
+
+	il.baseDirPriority = cfg.BaseDirPriority
+	il.customBaseDirs = cleanPaths(cfg.BaseDirs)
+	il.additionalBaseDirs = cleanPaths(cfg.AdditionalBaseDirs)
+	il.setBaseDirs(il.listBaseDirs())
+
+	il.onThemeWarning = cfg.OnThemeWarning
+	il.strictThemeIndex = cfg.StrictThemeIndex
+	il.overrides = maps.Clone(cfg.Overrides)
+	il.preferSymbolic = cfg.PreferSymbolic
+	il.preferFullColor = cfg.PreferFullColor
+	il.symbolicFallback = cfg.SymbolicFallback
+	il.fullColorFallback = cfg.FullColorFallback
+	il.genericFallback = cfg.GenericFallback
+	il.disablePixmapFallback = cfg.DisablePixmapFallback
+	il.preferScalable = cfg.PreferScalable
+	il.iconContext = cfg.Context
+
+	if cfg.AllowedRoots != nil {
+		il.allowedRoots = resolveRoots(cfg.AllowedRoots)
+	}
+
+	if cfg.ImplicitInherits != nil {
+		il.implicitInherits = slices.Clone(cfg.ImplicitInherits)
+	} else {
+		il.implicitInherits = desktopInherits()
+	}
+
+	il.colorScheme = cfg.ColorScheme
+	il.theme = il.themeVariant(il.theme)
+
 	il.createInitialCache()
+
+	if cfg.Watch {
+		_ = il.startWatching()
+	}
+	if cfg.BackgroundRefresh && il.refreshInterval() > 0 {
+		il.startRefresher()
+	}
+	if il.xsettings != nil {
+		go il.watchXSettings(il.xsettings)
+	}
+	if cfg.Theme == "" && cfg.FollowSystemTheme {
+		il.startFollowingTheme()
+	}
 	return il
 }
 
-// Finds a specified icon, defaults size to 48 and scale to 1
+// Finds a specified icon with the default size and scale
+// (48 and 1, unless configured otherwise)
 func (il *IconLookup) Lookup(iconName string) (Icon, error) {
-	icon, err := il.FindIcon(iconName, 48, 1)
+	return il.LookupContext(context.Background(), iconName)
+}
+
+// Like [IconLookup.Lookup], but gives up once ctx is done
+func (il *IconLookup) LookupContext(ctx context.Context, iconName string) (Icon, error) {
+	icon, err := il.FindIconContext(ctx, iconName, il.defaultSize, il.defaultScale)
 	return icon, err
 }
 
 // Finds a specified icon with required size and scale
+//
+// iconName may also be the absolute path of an existing
+// icon file, which is then returned as is.
 func (il *IconLookup) FindIcon(iconName string, size int, scale int) (Icon, error) {
-	icon, err := il.findIconHelper(iconName, size, scale, il.theme)
+	return il.FindIconContext(context.Background(), iconName, size, scale)
+}
+
+// Like [IconLookup.FindIcon], but gives up once ctx is done,
+// e.g. while a slow base directory is being rescanned.
+// Returns ctx.Err() in that case.
+func (il *IconLookup) FindIconContext(ctx context.Context, iconName string, size int, scale int) (Icon, error) {
+	return il.findIcon(ctx, iconName, size, scale, il.lookupOptions(LookupOptions{}))
+}
+
+func (il *IconLookup) findIcon(ctx context.Context, iconName string, size int, scale int, opts LookupOptions) (Icon, error) {
+	requestedName := iconName
+	icon, iconName, ok := il.applyOverride(iconName, opts.Extensions)
+	if ok {
+		return icon, nil
+	}
+
+	if names := il.nameVariants(iconName); len(names) > 1 {
+		icon, err := il.findBestIcon(ctx, names, size, scale, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return Icon{}, ctx.Err()
+			}
+			return Icon{}, il.iconNotFound([]string{requestedName}, opts)
+		}
+		return icon, nil
+	}
+
+	theme, fallbackTheme := il.Theme(), il.FallbackTheme()
+
+	icon, err := il.findIconHelper(ctx, iconName, size, scale, theme, opts)
 	if err == nil {
 		return icon, nil
 	}
 	// hicolor is always searched in findIconHelper since readIndex adds it to Inherits
 
-	icon, err = il.lookupFallbackIcon(iconName)
+	icon, err = il.lookupFallbackIcon(ctx, iconName, opts)
 	if err == nil {
 		return icon, nil
 	}
@@ -112,143 +515,123 @@ func (il *IconLookup) FindIcon(iconName string, size int, scale int) (Icon, erro
 	// searching a fallback theme as well... since some apps (blueman-applet)
 	// asks for bluetooth-symbolic which is not in hicolor (so specifying adwaita
 	// can be useful)
-	if il.fallbackTheme != "" {
-		icon, err = il.findIconHelper(iconName, size, scale, il.fallbackTheme)
+	if fallbackTheme != "" {
+		icon, err = il.findIconHelper(ctx, iconName, size, scale, fallbackTheme, opts)
 		if err == nil {
 			return icon, nil
 		}
 	}
-	return Icon{}, fmt.Errorf("icon %q not found", iconName)
+	if ctx.Err() != nil {
+		return Icon{}, ctx.Err()
+	}
+	return Icon{}, il.iconNotFound([]string{requestedName}, opts)
 }
 
-func (il *IconLookup) findIconHelper(iconName string, size int, scale int, theme string) (Icon, error) {
-	// fmt.Printf("Searching icon=%q size=%d scale=%d theme=%q\n", iconName, size, scale, theme)
-	themeInfo, err := il.getThemeInfo(theme)
-	if err != nil {
+func (il *IconLookup) findIconHelper(ctx context.Context, iconName string, size int, scale int, theme string, opts LookupOptions) (Icon, error) {
+	if err := ctx.Err(); err != nil {
 		return Icon{}, err
 	}
-
-	icon, err := il.lookupIcon(iconName, size, scale, theme)
-	if err == nil {
-		return icon, nil
+	// fmt.Printf("Searching icon=%q size=%d scale=%d theme=%q\n", iconName, size, scale, theme)
+	if _, err := il.getThemeInfo(theme); err != nil {
+		return Icon{}, err
 	}
 
-	for _, parent := range themeInfo.Inherits {
-		icon, err := il.findIconHelper(iconName, size, scale, parent)
+	for _, chainTheme := range il.themeChain(theme) {
+		icon, err := il.lookupIcon(ctx, iconName, size, scale, chainTheme, opts)
 		if err == nil {
 			return icon, nil
 		}
 	}
 
-	return Icon{}, fmt.Errorf("icon %q not found", iconName)
+	return Icon{}, &IconNotFoundError{Names: []string{iconName}}
 }
 
-func (il *IconLookup) lookupIcon(iconName string, size int, scale int, theme string) (Icon, error) {
-	themeInfo, err := il.getThemeInfo(theme)
+func (il *IconLookup) lookupIcon(ctx context.Context, iconName string, size int, scale int, theme string, opts LookupOptions) (Icon, error) {
+	icons, err := il.lookupIcons(ctx, []string{iconName}, size, scale, theme, opts)
 	if err != nil {
 		return Icon{}, err
 	}
+	if icon, ok := icons[iconName]; ok {
+		return icon, nil
+	}
 
-	for _, subdir := range append(themeInfo.Directories, themeInfo.ScaledDirectories...) {
-		for _, directory := range GetBaseDirs() {
-			for _, extension := range il.extensions {
-				if il.directoryMatchesSize(themeInfo, subdir, size, scale) {
-					iconPath := path.Join(directory, theme, subdir, iconName+"."+extension)
-					// fmt.Printf("[XDGICONS]: Searching for %q\n", iconPath)
-					if il.fileExists(directory, iconPath) {
-						iconInfo := themeInfo.directoryMap[subdir]
-						return Icon{
-							Name:    iconName,
-							Path:    iconPath,
-							Size:    iconInfo.Size,
-							MinSize: iconInfo.MinSize,
-							MaxSize: iconInfo.MaxSize,
-							Scale:   iconInfo.Scale,
-						}, nil
-					}
-				}
-			}
-		}
+	return Icon{}, &IconNotFoundError{Names: []string{iconName}}
+}
+
+func (il *IconLookup) lookupFallbackIcon(ctx context.Context, iconName string, opts LookupOptions) (Icon, error) {
+	if il.disablePixmapFallback || opts.ExactSize {
+		return Icon{}, &IconNotFoundError{Names: []string{iconName}}
 	}
 
-	minimalSize := math.MaxInt
-	var closestFilename string
-	var closestSubdir string
-
-	for _, subdir := range append(themeInfo.Directories, themeInfo.ScaledDirectories...) {
-		for _, directory := range GetBaseDirs() {
-			for _, extension := range il.extensions {
-				iconPath := path.Join(directory, theme, subdir, iconName+"."+extension)
-				if il.fileExists(directory, iconPath) && il.directorySizeDistance(themeInfo, subdir, size, scale) < minimalSize {
-					// fmt.Printf("[XDGICONS]: Searching for %q\n", iconPath)
-					closestFilename = iconPath
-					closestSubdir = subdir
-					minimalSize = il.directorySizeDistance(themeInfo, subdir, size, scale)
-				}
-			}
+	for _, directory := range il.getBaseDirs() {
+		if err := ctx.Err(); err != nil {
+			return Icon{}, err
+		}
+		// fmt.Printf("[XDGICONS]: Searching for %q\n", iconName)
+		if iconPath, ok := il.findFile(ctx, directory, "", iconName, opts.Extensions); ok {
+			return Icon{
+				Name: iconName,
+				Path: iconPath,
+			}, nil
 		}
-	}
-	if closestFilename != "" {
-		iconInfo := themeInfo.directoryMap[closestSubdir]
-		return Icon{
-			Name:    iconName,
-			Path:    closestFilename,
-			Size:    iconInfo.Size,
-			MinSize: iconInfo.MinSize,
-			MaxSize: iconInfo.MaxSize,
-			Scale:   iconInfo.Scale,
-		}, nil
 	}
 
-	return Icon{}, fmt.Errorf("icon %q not found", iconName)
+	return Icon{}, &IconNotFoundError{Names: []string{iconName}}
 }
 
-func (il *IconLookup) lookupFallbackIcon(iconName string) (Icon, error) {
-	for _, directory := range GetBaseDirs() {
-		for _, extension := range il.extensions {
-			iconPath := path.Join(directory, iconName+"."+extension)
-
-			// fmt.Printf("[XDGICONS]: Searching for %q\n", iconPath)
-			if il.fileExists(directory, iconPath) {
-				return Icon{
-					Name: iconName,
-					Path: iconPath,
-				}, nil
-			}
-		}
+// Returns the path of iconName in dir (relative to baseDir) with
+// the first of extensions it exists with.
+func (il *IconLookup) findFile(ctx context.Context, baseDir, dir, iconName string, extensions []string) (string, bool) {
+	cacheEntry := il.cacheEntry(ctx, baseDir)
+	if cacheEntry == nil {
+		return "", false
 	}
 
-	return Icon{}, fmt.Errorf("icon %q not found", iconName)
+	// the cached walk can be stale, so symlinks are resolved on every hit
+	return cacheEntry.find(baseDir, dir, iconName, extensions, il.pathAllowed)
 }
 
-func (il *IconLookup) fileExists(baseDir, iconPath string) bool {
+// returns the cache entry of baseDir, after revalidating it if due
+func (il *IconLookup) cacheEntry(ctx context.Context, baseDir string) *baseDirIconCache {
+	now := time.Now()
+
 	il.mu.RLock()
-	cacheEntry, exists := il.dirCache[baseDir]
+	cacheEntry := il.dirCache[baseDir]
+	// both are rebuilt on demand, even if watched or never rechecked
+	evicted := il.evictedDirs[baseDir] || il.invalidatedDirs[baseDir]
+	degraded := il.degradedDirs[baseDir] > 0
+	refreshed := il.refresher != nil
 	il.mu.RUnlock()
 
-	now := time.Now()
+	// skipped until it responds again
+	if degraded {
+		return nil
+	}
 
-	if !exists || now.Sub(cacheEntry.lastStat) >= il.cacheValidCheckInterval {
+	rescanned := false
+	if cacheEntry == nil && evicted {
+		cacheEntry = il.refreshBaseDirectory(ctx, baseDir)
+		rescanned = true
+	} else if interval := il.checkInterval(baseDir); !refreshed && interval >= 0 && (cacheEntry == nil || now.Sub(time.Unix(0, cacheEntry.lastStat.Load())) >= interval+cacheEntry.jitter) {
 		if il.shouldRefreshCache(baseDir, cacheEntry, now) {
-			il.mu.Lock()
-			il.cacheBaseDirectory(baseDir)
-			cacheEntry = il.dirCache[baseDir]
-			il.mu.Unlock()
-		} else if exists {
-			il.mu.Lock()
-			cacheEntry.lastStat = now
-			il.mu.Unlock()
+			// a missing base directory is only looked for again
+			rescanned = cacheEntry != nil
+			cacheEntry = il.refreshBaseDirectory(ctx, baseDir)
+		} else if cacheEntry != nil {
+			cacheEntry.lastStat.Store(now.UnixNano())
 		}
 	}
 
 	if cacheEntry == nil {
-		return false
+		return nil
 	}
-
-	il.mu.RLock()
-	defer il.mu.RUnlock()
-
-	return cacheEntry.files[iconPath]
+	if rescanned {
+		il.cacheMisses.Add(1)
+	} else {
+		il.cacheHits.Add(1)
+	}
+	cacheEntry.lastUsed.Store(now.UnixNano())
+	return cacheEntry
 }
 
 func (il *IconLookup) directoryMatchesSize(themeInfo ThemeInfo, subdir string, iconSize int, iconScale int) bool {
@@ -270,6 +653,14 @@ func (il *IconLookup) directoryMatchesSize(themeInfo ThemeInfo, subdir string, i
 	return false // this should be unreachable
 }
 
+// reports whether subdir belongs to the configured context, if any
+func (il *IconLookup) directoryInContext(themeInfo ThemeInfo, subdir string) bool {
+	if il.iconContext == "" {
+		return true
+	}
+	return strings.EqualFold(themeInfo.directoryMap[subdir].Context, il.iconContext)
+}
+
 func (il *IconLookup) directorySizeDistance(themeInfo ThemeInfo, subdir string, iconSize int, iconScale int) int {
 	subdirInfo := themeInfo.directoryMap[subdir]
 
@@ -300,7 +691,37 @@ func (il *IconLookup) directorySizeDistance(themeInfo ThemeInfo, subdir string,
 // Finds the first available icon in iconList with the required size and scale.
 // Searches in the order of listing.
 func (il *IconLookup) FindBestIcon(iconList []string, size int, scale int) (Icon, error) {
-	icon, err := il.findBestIconHelper(iconList, size, scale, il.theme)
+	return il.FindBestIconContext(context.Background(), iconList, size, scale)
+}
+
+// Like [IconLookup.FindBestIcon], but gives up once ctx is done.
+// Returns ctx.Err() in that case.
+func (il *IconLookup) FindBestIconContext(ctx context.Context, iconList []string, size int, scale int) (Icon, error) {
+	opts := il.lookupOptions(LookupOptions{})
+
+	var names []string
+	for _, iconName := range iconList {
+		icon, iconName, ok := il.applyOverride(iconName, opts.Extensions)
+		if ok {
+			return icon, nil
+		}
+		names = append(names, il.nameVariants(iconName)...)
+	}
+
+	icon, err := il.findBestIcon(ctx, names, size, scale, opts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return Icon{}, ctx.Err()
+		}
+		return Icon{}, il.iconNotFound(iconList, opts)
+	}
+	return icon, nil
+}
+
+func (il *IconLookup) findBestIcon(ctx context.Context, iconList []string, size int, scale int, opts LookupOptions) (Icon, error) {
+	theme, fallbackTheme := il.Theme(), il.FallbackTheme()
+
+	icon, err := il.findBestIconInTheme(ctx, iconList, size, scale, theme, opts)
 	if err == nil {
 		return icon, nil
 	}
@@ -309,7 +730,7 @@ func (il *IconLookup) FindBestIcon(iconList []string, size int, scale int) (Icon
 
 	// doing a fallback lookup in pixmaps directory
 	for _, iconName := range iconList {
-		icon, err := il.lookupFallbackIcon(iconName)
+		icon, err := il.lookupFallbackIcon(ctx, iconName, opts)
 		if err == nil {
 			return icon, nil
 		}
@@ -318,36 +739,33 @@ func (il *IconLookup) FindBestIcon(iconList []string, size int, scale int) (Icon
 	// searching a fallback theme as well... since some apps (blueman-applet)
 	// asks for bluetooth-symbolic which is not in hicolor (so specifying adwaita
 	// can be useful)
-	if il.fallbackTheme != "" {
-		icon, err = il.findBestIconHelper(iconList, size, scale, il.fallbackTheme)
+	if fallbackTheme != "" {
+		icon, err = il.findBestIconInTheme(ctx, iconList, size, scale, fallbackTheme, opts)
 		if err == nil {
 			return icon, nil
 		}
 	}
 
-	return Icon{}, fmt.Errorf("icons \"%s\" not found", strings.Join(iconList, ","))
+	return Icon{}, &IconNotFoundError{Names: iconList}
 }
 
-func (il *IconLookup) findBestIconHelper(iconList []string, size int, scale int, theme string) (Icon, error) {
-	// fmt.Printf("Searching icon=%q size=%d scale=%d theme=%q\n", iconName, size, scale, theme)
-	themeInfo, err := il.getThemeInfo(theme)
-	if err != nil {
+func (il *IconLookup) findBestIconHelper(ctx context.Context, iconList []string, size int, scale int, theme string, opts LookupOptions) (Icon, error) {
+	if err := ctx.Err(); err != nil {
 		return Icon{}, err
 	}
-
-	for _, iconName := range iconList {
-		icon, err := il.lookupIcon(iconName, size, scale, theme)
-		if err == nil {
-			return icon, nil
-		}
+	// fmt.Printf("Searching icon=%q size=%d scale=%d theme=%q\n", iconName, size, scale, theme)
+	if _, err := il.getThemeInfo(theme); err != nil {
+		return Icon{}, err
 	}
 
-	for _, parent := range themeInfo.Inherits {
-		icon, err := il.findBestIconHelper(iconList, size, scale, parent)
-		if err == nil {
-			return icon, nil
+	for _, chainTheme := range il.themeChain(theme) {
+		for _, iconName := range iconList {
+			icon, err := il.lookupIcon(ctx, iconName, size, scale, chainTheme, opts)
+			if err == nil {
+				return icon, nil
+			}
 		}
 	}
 
-	return Icon{}, fmt.Errorf("icons \"%s\" not found", strings.Join(iconList, ","))
+	return Icon{}, &IconNotFoundError{Names: iconList}
 }
@@ -0,0 +1,154 @@
+package xdgicons_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/codelif/xdgicons"
+	"github.com/codelif/xdgicons/testutil"
+)
+
+var raceNames = []string{"alpha-only", "beta-only", "shared", "app", "scalable-only", "missing"}
+
+// result of a lookup, comparable across lookups
+type raceResult struct {
+	path string
+	err  string
+}
+
+func raceThemes() []testutil.Theme {
+	return []testutil.Theme{
+		{
+			Name:     "Alpha",
+			Inherits: []string{"hicolor"},
+			Dirs: []testutil.Dir{
+				{Path: "48x48/apps", Size: 48, Icons: []string{"alpha-only.png", "shared.png"}},
+				{Path: "scalable/apps", Size: 48, MinSize: 8, MaxSize: 512, Type: "Scalable", Icons: []string{"scalable-only.svg"}},
+			},
+		},
+		{
+			Name: "Beta",
+			Dirs: []testutil.Dir{
+				{Path: "48x48/apps", Size: 48, Icons: []string{"beta-only.png", "shared.png"}},
+			},
+		},
+		testutil.Hicolor("app.png", "app.svg"),
+	}
+}
+
+func raceLookup(il *xdgicons.IconLookup, iconName string) raceResult {
+	icon, err := il.FindIcon(iconName, 48, 1)
+	if err != nil {
+		return raceResult{err: err.Error()}
+	}
+	return raceResult{path: icon.Path}
+}
+
+// results of a lookup that nothing else uses at the same time
+func expectedResults(t *testing.T, theme string) map[string]raceResult {
+	t.Helper()
+
+	il := xdgicons.NewIconLookupWithConfig(xdgicons.LookupConfig{Theme: theme})
+	expected := make(map[string]raceResult)
+	for _, iconName := range raceNames {
+		expected[iconName] = raceLookup(il, iconName)
+	}
+	return expected
+}
+
+// Lookups running while the theme is switched, the caches are dropped,
+// the environment is reloaded and the watcher rescans must neither race
+// nor return anything a lookup on its own wouldn't. Run with -race.
+func TestConcurrentLookups(t *testing.T) {
+	baseDir := testutil.SetupEnv(t, raceThemes()...)
+
+	alpha := expectedResults(t, "Alpha")
+	beta := expectedResults(t, "Beta")
+	if alpha["alpha-only"].path == "" || beta["beta-only"].path == "" {
+		t.Fatalf("test themes weren't found: %v %v", alpha, beta)
+	}
+
+	il := xdgicons.NewIconLookupWithConfig(xdgicons.LookupConfig{Theme: "Alpha", Watch: true})
+	t.Cleanup(func() { il.Close() })
+
+	const rounds = 200
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				for _, iconName := range raceNames {
+					got := raceLookup(il, iconName)
+					// the theme may be switched between any two lookups
+					if got != alpha[iconName] && got != beta[iconName] {
+						errs <- fmt.Errorf("FindIcon(%q) = %+v, want %+v or %+v", iconName, got, alpha[iconName], beta[iconName])
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range rounds {
+			theme := "Alpha"
+			if i%2 == 1 {
+				theme = "Beta"
+			}
+			if err := il.SetTheme(theme); err != nil {
+				errs <- fmt.Errorf("SetTheme(%q): %v", theme, err)
+				return
+			}
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range rounds / 4 {
+			il.InvalidateAll()
+			il.ReloadEnvironment()
+		}
+	}()
+
+	// changes picked up by the watcher, rescanning the base directory
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		appsDir := filepath.Join(baseDir, "Alpha", "48x48", "apps")
+		for i := range rounds / 4 {
+			churn := filepath.Join(appsDir, fmt.Sprintf("churn-%d.png", i))
+			if err := os.WriteFile(churn, nil, 0o644); err != nil {
+				errs <- err
+				return
+			}
+			if err := os.Remove(churn); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if err := il.SetTheme("Alpha"); err != nil {
+		t.Fatal(err)
+	}
+	for _, iconName := range raceNames {
+		if got := raceLookup(il, iconName); got != alpha[iconName] {
+			t.Errorf("after the stress, FindIcon(%q) = %+v, want %+v", iconName, got, alpha[iconName])
+		}
+	}
+}
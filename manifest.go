@@ -0,0 +1,166 @@
+package xdgicons
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Checksums of every file belonging to an installed theme
+type Manifest struct {
+	// Name of the theme directory
+	Theme string `json:"theme"`
+
+	// Time the manifest was generated
+	Created time.Time `json:"created"`
+
+	// Full path of every file in the theme, across all
+	// base directories, mapped to its hex encoded sha256 sum.
+	// Symlinks that don't point to a file are mapped to
+	// "link:" followed by their target.
+	Files map[string]string `json:"files"`
+}
+
+// marks the targets of symlinks in Manifest.Files
+const linkPrefix = "link:"
+
+// Differences between a Manifest and the installed theme
+type VerifyResult struct {
+	// Files whose contents differ from the manifest
+	Modified []string
+
+	// Files listed in the manifest but not installed
+	Missing []string
+
+	// Installed files not listed in the manifest
+	Extra []string
+}
+
+// reports whether the installed theme matches the manifest
+func (r VerifyResult) OK() bool {
+	return len(r.Modified) == 0 && len(r.Missing) == 0 && len(r.Extra) == 0
+}
+
+// Records the checksum of every file of theme in every base directory
+func (il *IconLookup) GenerateManifest(theme string) (Manifest, error) {
+	manifest := Manifest{
+		Theme:   theme,
+		Created: time.Now(),
+		Files:   make(map[string]string),
+	}
+
+	found := false
+	for _, directory := range il.getBaseDirs() {
+		themeDir := path.Join(directory, theme)
+		stat, err := os.Stat(themeDir)
+		if err != nil || !stat.IsDir() || !il.pathAllowed(themeDir) {
+			continue
+		}
+		found = true
+
+		err = filepath.WalkDir(themeDir, func(subPath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !il.pathAllowed(subPath) {
+				return nil
+			}
+
+			// dangling links and links to directories have no contents
+			if d.Type()&fs.ModeSymlink != 0 {
+				if stat, err := os.Stat(subPath); err != nil || !stat.Mode().IsRegular() {
+					target, err := os.Readlink(subPath)
+					if err != nil {
+						return err
+					}
+					manifest.Files[subPath] = linkPrefix + target
+					return nil
+				}
+			}
+
+			sum, err := checksumFile(subPath)
+			if err != nil {
+				return err
+			}
+			manifest.Files[subPath] = sum
+			return nil
+		})
+		if err != nil {
+			return Manifest{}, fmt.Errorf("error walking theme directory: %v", err)
+		}
+	}
+
+	if !found {
+		return Manifest{}, &ThemeNotFoundError{Theme: theme}
+	}
+
+	return manifest, nil
+}
+
+// Compares the installed files of theme against a previously generated manifest
+func (il *IconLookup) Verify(theme string, manifest Manifest) (VerifyResult, error) {
+	current, err := il.GenerateManifest(theme)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+
+	var result VerifyResult
+	for filePath, sum := range manifest.Files {
+		currentSum, ok := current.Files[filePath]
+		if !ok {
+			result.Missing = append(result.Missing, filePath)
+		} else if currentSum != sum {
+			result.Modified = append(result.Modified, filePath)
+		}
+	}
+
+	for filePath := range current.Files {
+		if _, ok := manifest.Files[filePath]; !ok {
+			result.Extra = append(result.Extra, filePath)
+		}
+	}
+
+	slices.Sort(result.Modified)
+	slices.Sort(result.Missing)
+	slices.Sort(result.Extra)
+	return result, nil
+}
+
+// Writes manifest as JSON
+func WriteManifest(w io.Writer, manifest Manifest) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(manifest)
+}
+
+// Reads a manifest written by WriteManifest
+func ReadManifest(r io.Reader) (Manifest, error) {
+	var manifest Manifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return Manifest{}, fmt.Errorf("error decoding manifest: %v", err)
+	}
+	return manifest, nil
+}
+
+func checksumFile(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
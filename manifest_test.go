@@ -0,0 +1,46 @@
+package xdgicons_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codelif/xdgicons"
+	"github.com/codelif/xdgicons/testutil"
+)
+
+// Themes commonly contain dangling links and links to directories
+func TestGenerateManifestSymlinks(t *testing.T) {
+	baseDir := testutil.SetupEnv(t, testutil.Hicolor("app.png"))
+	themeDir := filepath.Join(baseDir, "hicolor")
+	if err := os.Symlink("missing.png", filepath.Join(themeDir, "48x48", "apps", "dangling.png")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("48x48", filepath.Join(themeDir, "48x48@1")); err != nil {
+		t.Fatal(err)
+	}
+
+	il := xdgicons.NewIconLookupWithConfig(xdgicons.LookupConfig{})
+	manifest, err := il.GenerateManifest("hicolor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for link, target := range map[string]string{
+		filepath.Join(themeDir, "48x48", "apps", "dangling.png"): "missing.png",
+		filepath.Join(themeDir, "48x48@1"):                       "48x48",
+	} {
+		if got := manifest.Files[link]; !strings.HasSuffix(got, target) {
+			t.Errorf("manifest has %q for %s, want its target %q", got, link, target)
+		}
+	}
+	if sum := manifest.Files[filepath.Join(themeDir, "48x48", "apps", "app.png")]; len(sum) != 64 {
+		t.Errorf("manifest has %q for app.png, want its sha256", sum)
+	}
+
+	result, err := il.Verify("hicolor", manifest)
+	if err != nil || !result.OK() {
+		t.Errorf("Verify() = %+v, %v, want no differences", result, err)
+	}
+}
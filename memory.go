@@ -0,0 +1,149 @@
+package xdgicons
+
+import (
+	"maps"
+	"strings"
+	"time"
+)
+
+// rough overhead of a name in the icon index (string header, slice
+// header) and of a directory (map entry, slice headers)
+const fileEntryOverhead = 40
+
+// rough size of an extension in the icon index (string header)
+const extensionOverhead = 16
+
+// rough overhead of a parsed SubDirIconInfo in directoryMap
+const subDirInfoOverhead = 80
+
+// Estimated memory held by the caches of an IconLookup
+type MemoryReport struct {
+	// Estimated bytes held by the file index of each base directory
+	BaseDirs map[string]int
+
+	// Estimated bytes held by each theme, that is the file index
+	// entries under its directories plus its parsed index.theme
+	Themes map[string]int
+
+	// Estimated bytes held in total
+	Total int
+}
+
+// Estimates the memory held per base directory and per theme
+func (il *IconLookup) MemoryReport() MemoryReport {
+	il.mu.RLock()
+	defer il.mu.RUnlock()
+
+	report := MemoryReport{
+		BaseDirs: make(map[string]int),
+		Themes:   make(map[string]int),
+	}
+
+	for baseDir, cacheEntry := range il.dirCache {
+		size := 0
+		for dir, icons := range cacheEntry.icons {
+			dirSize := indexDirSize(dir, icons)
+			size += dirSize
+
+			// dir is "" for the files directly in the base directory
+			if dir != "" {
+				theme, _, _ := strings.Cut(dir, "/")
+				report.Themes[theme] += dirSize
+			}
+		}
+		report.BaseDirs[baseDir] = size
+		report.Total += size
+	}
+
+	for theme, themeInfo := range il.themeInfoCache {
+		size := themeInfoSize(themeInfo)
+		report.Themes[theme] += size
+		report.Total += size
+	}
+
+	return report
+}
+
+// Drops the cached files and index.theme data of every theme
+// that has not been used by a lookup for at least unusedFor.
+//
+// Compacted themes are indexed again the next time they are used.
+// Returns the names of the compacted themes.
+func (il *IconLookup) Compact(unusedFor time.Duration) []string {
+	il.mu.Lock()
+	defer il.mu.Unlock()
+
+	now := time.Now()
+	themes := maps.Clone(il.loadedThemes)
+	for theme := range il.themeInfoCache {
+		themes[theme] = true
+	}
+
+	var compacted []string
+	for theme := range themes {
+		if now.Sub(il.themeLastUsed[theme]) < unusedFor {
+			continue
+		}
+
+		for baseDir, cacheEntry := range il.dirCache {
+			if cacheEntry.themes[theme] {
+				il.dirCache[baseDir] = cacheEntry.withoutTheme(baseDir, theme)
+			}
+		}
+		delete(il.themeInfoCache, theme)
+		delete(il.themeLastUsed, theme)
+		delete(il.loadedThemes, theme)
+		compacted = append(compacted, theme)
+	}
+
+	return compacted
+}
+
+// records that theme is used by a lookup, indexing its
+// files if it wasn't used before or was compacted since
+func (il *IconLookup) touchTheme(theme string) {
+	now := time.Now()
+
+	il.mu.RLock()
+	lastUsed := il.themeLastUsed[theme]
+	loaded := il.loadedThemes[theme]
+	il.mu.RUnlock()
+
+	// avoid taking the write lock on every single lookup
+	if loaded && now.Sub(lastUsed) < time.Second {
+		return
+	}
+
+	il.mu.Lock()
+	il.themeLastUsed[theme] = now
+	il.mu.Unlock()
+
+	if !loaded {
+		il.loadTheme(theme, true)
+	}
+}
+
+// estimates the memory held by the icons of dir in the icon index
+func indexDirSize(dir string, icons *dirIcons) int {
+	size := len(dir) + fileEntryOverhead
+	for i, iconName := range icons.names {
+		size += len(iconName) + fileEntryOverhead
+		for _, extension := range icons.extensions[i] {
+			size += len(extension) + extensionOverhead
+		}
+	}
+	return size
+}
+
+func themeInfoSize(themeInfo ThemeInfo) int {
+	size := len(themeInfo.Name)
+	for _, list := range [][]string{themeInfo.Inherits, themeInfo.Directories, themeInfo.ScaledDirectories} {
+		for _, s := range list {
+			size += len(s) + 16
+		}
+	}
+	for subdir, subdirInfo := range themeInfo.directoryMap {
+		size += len(subdir) + len(subdirInfo.Type) + subDirInfoOverhead
+	}
+	return size
+}
@@ -0,0 +1,38 @@
+package xdgicons
+
+import "context"
+
+// Settings of a single lookup, see [IconLookup.FindIconWithOptions].
+type LookupOptions struct {
+	// Icon file extensions to search for, in order of preference,
+	// e.g. ["svg", "png"] for HiDPI outputs.
+	//
+	// If unset, uses the extensions of the IconLookup
+	Extensions []string
+
+	// Only return icons from directories matching the requested size
+	// and scale, instead of falling back to the closest size. Unthemed
+	// icons, whose size is unknown, are not returned either.
+	ExactSize bool
+
+	// Prefer SVGs in Scalable directories of the requested scale over
+	// raster icons of the exact size, for callers rendering icons
+	// themselves. Also enabled by [LookupConfig.PreferScalable].
+	PreferScalable bool
+}
+
+// Finds a specified icon with required size and scale, like
+// [IconLookup.FindIcon], using opts instead of the IconLookup's
+// settings where set.
+func (il *IconLookup) FindIconWithOptions(iconName string, size int, scale int, opts LookupOptions) (Icon, error) {
+	return il.findIcon(context.Background(), iconName, size, scale, il.lookupOptions(opts))
+}
+
+// fills in the unset fields of opts from the IconLookup
+func (il *IconLookup) lookupOptions(opts LookupOptions) LookupOptions {
+	if len(opts.Extensions) == 0 {
+		opts.Extensions = il.extensions
+	}
+	opts.PreferScalable = opts.PreferScalable || il.preferScalable
+	return opts
+}
@@ -0,0 +1,54 @@
+package xdgicons
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Applies the configured override of iconName.
+//
+// Returns the icon directly if the override (or iconName itself, e.g.
+// from the Icon key of a .desktop file) is an absolute path to an
+// existing file with one of extensions, otherwise the name to search
+// for instead of iconName.
+func (il *IconLookup) applyOverride(iconName string, extensions []string) (Icon, string, bool) {
+	target, ok := il.overrides[iconName]
+	if !ok {
+		if icon, ok := il.iconAtPath(iconName, iconName, extensions); ok {
+			return icon, iconName, true
+		}
+		return Icon{}, iconName, false
+	}
+
+	if !filepath.IsAbs(target) {
+		return Icon{}, target, false
+	}
+
+	if icon, ok := il.iconAtPath(iconName, target, extensions); ok {
+		return icon, iconName, true
+	}
+	// a stale override shouldn't hide the themed icon
+	return Icon{}, iconName, false
+}
+
+// returns an Icon for the file at iconPath, if it is an absolute
+// path to an existing, allowed file with one of extensions
+func (il *IconLookup) iconAtPath(iconName, iconPath string, extensions []string) (Icon, bool) {
+	if !filepath.IsAbs(iconPath) {
+		return Icon{}, false
+	}
+	// e.g. Lookup("/etc/shadow") shouldn't return a file that isn't an image
+	extension := strings.TrimPrefix(filepath.Ext(iconPath), ".")
+	if !slices.ContainsFunc(extensions, func(e string) bool { return strings.EqualFold(e, extension) }) {
+		return Icon{}, false
+	}
+
+	stat, err := os.Stat(iconPath)
+	if err != nil || stat.IsDir() || !il.pathAllowed(iconPath) {
+		return Icon{}, false
+	}
+
+	return Icon{Name: iconName, Path: iconPath}, true
+}
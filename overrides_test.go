@@ -0,0 +1,39 @@
+package xdgicons_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codelif/xdgicons"
+	"github.com/codelif/xdgicons/testutil"
+)
+
+// Absolute paths are only returned as icons if they are image files
+func TestAbsolutePathNeedsIconExtension(t *testing.T) {
+	testutil.SetupEnv(t, testutil.Hicolor("app.png"))
+
+	dir := t.TempDir()
+	imagePath := filepath.Join(dir, "custom.png")
+	otherPath := filepath.Join(dir, "secret")
+	for _, p := range []string{imagePath, otherPath} {
+		if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	il := xdgicons.NewIconLookupWithConfig(xdgicons.LookupConfig{
+		Overrides: map[string]string{"overridden": otherPath},
+	})
+
+	icon, err := il.Lookup(imagePath)
+	if err != nil || icon.Path != imagePath {
+		t.Errorf("Lookup(%q) = %+v, %v, want the file", imagePath, icon, err)
+	}
+	if icon, err := il.Lookup(otherPath); err == nil {
+		t.Errorf("Lookup(%q) = %+v, want an error", otherPath, icon)
+	}
+	if icon, err := il.Lookup("overridden"); err == nil {
+		t.Errorf("Lookup of an override to %q = %+v, want an error", otherPath, icon)
+	}
+}
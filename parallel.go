@@ -0,0 +1,146 @@
+package xdgicons
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// candidate lists at least this long are resolved concurrently
+const parallelBestIconThreshold = 4
+
+// Resolves iconList in theme and its parents, concurrently for long
+// lists, while keeping the order findBestIconHelper would search in.
+func (il *IconLookup) findBestIconInTheme(ctx context.Context, iconList []string, size int, scale int, theme string, opts LookupOptions) (Icon, error) {
+	if len(iconList) < parallelBestIconThreshold {
+		return il.findBestIconHelper(ctx, iconList, size, scale, theme, opts)
+	}
+	return il.findBestIconParallel(ctx, iconList, size, scale, theme, opts)
+}
+
+// Lists the themes searched for theme: theme and the ones it inherits
+// from, depth first, each once. Themes that aren't installed are left
+// out. Broken themes can inherit from themselves through their parents,
+// such cycles are cut and reported as a warning of the theme closing it.
+func (il *IconLookup) themeChain(theme string) []string {
+	var chain []string
+	var walk func(theme string, ancestors []string)
+	walk = func(theme string, ancestors []string) {
+		themeInfo, err := il.getThemeInfo(theme)
+		if err != nil {
+			return
+		}
+		chain = append(chain, theme)
+
+		ancestors = append(ancestors, theme)
+		for _, parent := range themeInfo.Inherits {
+			if i := slices.Index(ancestors, parent); i >= 0 {
+				cycle := append(slices.Clone(ancestors[i:]), parent)
+				il.addThemeWarning(theme, fmt.Sprintf("inheritance cycle %s, %s is skipped",
+					strings.Join(cycle, " -> "), parent))
+				continue
+			}
+			// already searched along with its parents
+			if slices.Contains(chain, parent) {
+				continue
+			}
+			walk(parent, ancestors)
+		}
+	}
+	walk(theme, nil)
+	return chain
+}
+
+// Every (theme, name) pair of the search is looked up by a bounded pool
+// of workers. As soon as a pair resolves and all pairs ordered before it
+// are known to have failed, the remaining lookups are cancelled.
+func (il *IconLookup) findBestIconParallel(ctx context.Context, iconList []string, size int, scale int, theme string, opts LookupOptions) (Icon, error) {
+	type task struct {
+		theme    string
+		iconName string
+	}
+
+	var tasks []task
+	for _, chainTheme := range il.themeChain(theme) {
+		for _, iconName := range iconList {
+			tasks = append(tasks, task{chainTheme, iconName})
+		}
+	}
+	if len(tasks) == 0 {
+		return il.findBestIconHelper(ctx, iconList, size, scale, theme, opts)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type completion struct {
+		index int
+		err   error
+	}
+
+	const (
+		pending = iota
+		found
+		failed
+	)
+	results := make([]Icon, len(tasks))
+	completed := make(chan completion, len(tasks))
+
+	var next atomic.Int64
+	for range min(len(tasks), runtime.GOMAXPROCS(0)) {
+		go func() {
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(tasks) || ctx.Err() != nil {
+					return
+				}
+
+				icon, err := il.lookupIcon(ctx, tasks[i].iconName, size, scale, tasks[i].theme, opts)
+				if err == nil {
+					results[i] = icon
+				}
+				completed <- completion{i, err}
+			}
+		}()
+	}
+
+	// results[i] is only read after i was received on completed
+	status := make([]int, len(tasks))
+	lowest := 0
+	for range tasks {
+		var c completion
+		select {
+		case c = <-completed:
+		case <-ctx.Done():
+			return Icon{}, ctx.Err()
+		}
+		// a search given up on isn't a miss, lower priority
+		// results must not be returned in its place
+		if ctx.Err() != nil || errors.Is(c.err, context.Canceled) || errors.Is(c.err, context.DeadlineExceeded) {
+			if err := ctx.Err(); err != nil {
+				return Icon{}, err
+			}
+			return Icon{}, c.err
+		}
+		status[c.index] = failed
+		if c.err == nil {
+			status[c.index] = found
+		}
+
+		for lowest < len(tasks) && status[lowest] == failed {
+			lowest++
+		}
+		if lowest == len(tasks) {
+			break
+		}
+		if status[lowest] == found {
+			return results[lowest], nil
+		}
+	}
+
+	return Icon{}, &IconNotFoundError{Names: iconList}
+}
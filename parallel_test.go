@@ -0,0 +1,27 @@
+package xdgicons_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/codelif/xdgicons"
+	"github.com/codelif/xdgicons/testutil"
+)
+
+// A cancelled search must not be taken for a miss, letting a
+// lower priority candidate be returned instead of ctx.Err()
+func TestFindBestIconCancelled(t *testing.T) {
+	testutil.SetupEnv(t, testutil.Hicolor("last.png"))
+	il := xdgicons.NewIconLookupWithConfig(xdgicons.LookupConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	names := []string{"first", "second", "third", "fourth", "last"}
+	for range 50 {
+		if icon, err := il.FindBestIconContext(ctx, names, 48, 1); !errors.Is(err, context.Canceled) {
+			t.Fatalf("FindBestIconContext() = %+v, %v, want context.Canceled", icon, err)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package xdgicons
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Start of every cache snapshot
+const cacheMagic = "XDGICONS\x00CACHE\n"
+
+// Format version of cache snapshots. Bumped whenever the format or
+// the meaning of its contents changes, so snapshots written by other
+// versions of the package are rejected instead of misread.
+const cacheVersion uint32 = 4
+
+type cacheSnapshot struct {
+	BaseDirs []baseDirSnapshot
+}
+
+type baseDirSnapshot struct {
+	Path string
+
+	// Whether the directory didn't exist
+	Missing bool
+
+	// Whether the directory was dropped from the cache
+	// because [LookupConfig.MaxCachedFiles] was exceeded
+	Evicted bool
+
+	// Whether the directory was dropped by [IconLookup.InvalidateAll]
+	Invalidated bool
+
+	Mtime     time.Time
+	Themes    []string
+	DirMtimes map[string]time.Time
+	Links     map[string]string
+	Icons     map[string]map[string][]string
+
+	// themes indexed through their icon-theme.cache
+	GTKCaches []string
+}
+
+// Writes the directory index to w, so a later process can
+// restore it with [IconLookup.LoadCache] instead of scanning.
+//
+// The snapshot starts with a magic header and a format version,
+// and records the mtimes the index was built from.
+func (il *IconLookup) SaveCache(w io.Writer) error {
+	il.mu.RLock()
+	var snapshot cacheSnapshot
+	for _, baseDir := range il.baseDirs {
+		cacheEntry := il.dirCache[baseDir]
+		if cacheEntry == nil {
+			evicted := il.evictedDirs[baseDir]
+			invalidated := il.invalidatedDirs[baseDir]
+			snapshot.BaseDirs = append(snapshot.BaseDirs, baseDirSnapshot{
+				Path:        baseDir,
+				Missing:     !evicted && !invalidated,
+				Evicted:     evicted,
+				Invalidated: invalidated,
+			})
+			continue
+		}
+		snapshot.BaseDirs = append(snapshot.BaseDirs, baseDirSnapshot{
+			Path:      baseDir,
+			Mtime:     cacheEntry.mtime,
+			Themes:    slices.Sorted(maps.Keys(cacheEntry.themes)),
+			DirMtimes: cacheEntry.dirMtimes,
+			Links:     cacheEntry.links,
+			Icons:     cacheEntry.icons.export(),
+			GTKCaches: slices.Sorted(maps.Keys(cacheEntry.gtkCaches)),
+		})
+	}
+	il.mu.RUnlock()
+
+	var buf bytes.Buffer
+	buf.WriteString(cacheMagic)
+	_ = binary.Write(&buf, binary.BigEndian, cacheVersion)
+	if err := gob.NewEncoder(&buf).Encode(&snapshot); err != nil {
+		return fmt.Errorf("error encoding cache snapshot: %v", err)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Replaces the directory index with a snapshot written by
+// [IconLookup.SaveCache], marking its themes as indexed.
+//
+// The snapshot is only used if it is valid, as reported by
+// [ValidateCache], and was written for the same base directories.
+// Otherwise the error is returned and the current index is kept,
+// which is built by scanning as usual.
+func (il *IconLookup) LoadCache(r io.Reader) error {
+	snapshot, err := readCacheSnapshot(r)
+	if err != nil {
+		return err
+	}
+
+	baseDirs := il.getBaseDirs()
+	if len(snapshot.BaseDirs) != len(baseDirs) {
+		return fmt.Errorf("%w: written for other base directories", ErrCacheStale)
+	}
+	for i, dir := range snapshot.BaseDirs {
+		if dir.Path != baseDirs[i] {
+			return fmt.Errorf("%w: written for other base directories", ErrCacheStale)
+		}
+	}
+
+	dirCache := make(map[string]*baseDirIconCache)
+	evictedDirs := make(map[string]bool)
+	invalidatedDirs := make(map[string]bool)
+	missingDirs := make(map[string]missingDir)
+	var loaded map[string]bool
+	for _, dir := range snapshot.BaseDirs {
+		// validated to still be missing
+		if dir.Missing {
+			missingDirs[dir.Path] = missingDir{checked: time.Now(), jitter: il.randomJitter()}
+		}
+		if dir.Evicted {
+			evictedDirs[dir.Path] = true
+		}
+		if dir.Invalidated {
+			invalidatedDirs[dir.Path] = true
+		}
+		if dir.Missing || dir.Evicted || dir.Invalidated || !il.pathAllowed(dir.Path) {
+			continue
+		}
+		cacheEntry, err := dir.restore(il.randomJitter())
+		if err != nil {
+			return err
+		}
+		dirCache[dir.Path] = cacheEntry
+
+		// only themes indexed in every base directory count as loaded
+		if loaded == nil {
+			loaded = maps.Clone(cacheEntry.themes)
+		} else {
+			maps.DeleteFunc(loaded, func(theme string, _ bool) bool {
+				return !cacheEntry.themes[theme]
+			})
+		}
+	}
+	if loaded == nil {
+		loaded = make(map[string]bool)
+	}
+
+	il.mu.Lock()
+	defer il.mu.Unlock()
+
+	il.dirCache = dirCache
+	il.dirGeneration++
+	il.loadedThemes = loaded
+	il.loadGeneration++
+	il.evictedDirs = evictedDirs
+	il.invalidatedDirs = invalidatedDirs
+	il.missingDirs = missingDirs
+	il.clearThemeInfoCache()
+	il.missingThemes = make(map[string]bool)
+	il.evictCache("")
+	return nil
+}
+
+// Checks a snapshot written by [IconLookup.SaveCache] without using it.
+//
+// Returns an error matching [ErrCacheCorrupt] if it can't be decoded,
+// [ErrCacheVersion] if it was written in another format version, and
+// [ErrCacheStale] if any of the directories it was built from changed.
+func ValidateCache(r io.Reader) error {
+	_, err := readCacheSnapshot(r)
+	return err
+}
+
+// decodes a snapshot and checks that it is still up to date
+func readCacheSnapshot(r io.Reader) (*cacheSnapshot, error) {
+	magic := make([]byte, len(cacheMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != cacheMagic {
+		return nil, fmt.Errorf("%w: bad magic header", ErrCacheCorrupt)
+	}
+
+	var version uint32
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, fmt.Errorf("%w: missing version", ErrCacheCorrupt)
+	}
+	if version != cacheVersion {
+		return nil, fmt.Errorf("%w: %d, expected %d", ErrCacheVersion, version, cacheVersion)
+	}
+
+	var snapshot cacheSnapshot
+	if err := gob.NewDecoder(r).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCacheCorrupt, err)
+	}
+
+	for _, dir := range snapshot.BaseDirs {
+		if err := dir.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &snapshot, nil
+}
+
+// reports an error matching ErrCacheStale if the directory changed
+func (dir *baseDirSnapshot) validate() error {
+	if dir.Evicted || dir.Invalidated {
+		return nil
+	}
+
+	stat, err := os.Stat(dir.Path)
+	if dir.Missing {
+		if err == nil {
+			return fmt.Errorf("%w: %s was created", ErrCacheStale, dir.Path)
+		}
+		return nil
+	}
+	if err != nil || !stat.ModTime().Equal(dir.Mtime) {
+		return fmt.Errorf("%w: %s changed", ErrCacheStale, dir.Path)
+	}
+
+	for dirPath, mtime := range dir.DirMtimes {
+		stat, err := os.Stat(dirPath)
+		if err != nil || !stat.ModTime().Equal(mtime) {
+			return fmt.Errorf("%w: %s changed", ErrCacheStale, dirPath)
+		}
+	}
+	for dirPath, target := range dir.Links {
+		if real, err := filepath.EvalSymlinks(dirPath); err != nil || real != target {
+			return fmt.Errorf("%w: %s points elsewhere", ErrCacheStale, dirPath)
+		}
+	}
+	return nil
+}
+
+// builds the cache entry described by dir, reopening
+// the icon-theme.cache files it refers to
+func (dir *baseDirSnapshot) restore(jitter time.Duration) (*baseDirIconCache, error) {
+	gtkCaches := make(map[string]*gtkIconCache)
+	for _, theme := range dir.GTKCaches {
+		gtkCache := openGTKIconCache(path.Join(dir.Path, theme))
+		if gtkCache == nil {
+			return nil, fmt.Errorf("%w: icon-theme.cache of %s is outdated", ErrCacheStale, theme)
+		}
+		gtkCaches[theme] = gtkCache
+	}
+
+	icons := importIndex(dir.Icons)
+	themes := make(map[string]bool, len(dir.Themes))
+	for _, theme := range dir.Themes {
+		themes[theme] = true
+	}
+	dirMtimes := dir.DirMtimes
+	if dirMtimes == nil {
+		dirMtimes = make(map[string]time.Time)
+	}
+	links := dir.Links
+	if links == nil {
+		links = make(map[string]string)
+	}
+
+	now := time.Now()
+	cacheEntry := &baseDirIconCache{
+		icons:     icons,
+		files:     icons.count(),
+		gtkCaches: gtkCaches,
+		dirMtimes: dirMtimes,
+		links:     links,
+		themes:    themes,
+		mtime:     dir.Mtime,
+		lastScan:  now,
+		jitter:    jitter,
+	}
+	cacheEntry.lastStat.Store(now.UnixNano())
+	cacheEntry.lastUsed.Store(now.UnixNano())
+	return cacheEntry, nil
+}
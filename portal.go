@@ -0,0 +1,80 @@
+package xdgicons
+
+import (
+	"context"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// how long to wait for the settings portal before falling back
+const portalTimeout = time.Second
+
+// Reads the icon theme from the org.freedesktop.portal.Settings
+// interface of the desktop portal, which works inside sandboxes and
+// without dconf installed. Returns "" if there's no session bus or
+// portal, or the setting isn't set.
+func portalIconTheme() string {
+	value, ok := readPortalSetting("org.gnome.desktop.interface", "icon-theme")
+	if !ok {
+		return ""
+	}
+	theme, _ := value.Value().(string)
+	return theme
+}
+
+// Reads the color-scheme of the org.freedesktop.appearance namespace
+// of the settings portal. NoColorScheme if there's no preference.
+func portalColorScheme() ColorScheme {
+	value, ok := readPortalSetting("org.freedesktop.appearance", "color-scheme")
+	if !ok {
+		return NoColorScheme
+	}
+	scheme, _ := value.Value().(uint32)
+	switch scheme {
+	case 1:
+		return PreferDark
+	case 2:
+		return PreferLight
+	}
+	return NoColorScheme
+}
+
+// reads key of namespace from the settings portal, false if there's
+// no session bus or portal, or the setting isn't set
+func readPortalSetting(namespace, key string) (dbus.Variant, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), portalTimeout)
+	defer cancel()
+
+	// never start a bus just to ask for the theme
+	conn, err := dbus.SessionBusPrivateNoAutoStartup(dbus.WithContext(ctx))
+	if err != nil {
+		return dbus.Variant{}, false
+	}
+	defer conn.Close()
+	if err := conn.Auth(nil); err != nil {
+		return dbus.Variant{}, false
+	}
+	if err := conn.Hello(); err != nil {
+		return dbus.Variant{}, false
+	}
+
+	portal := conn.Object("org.freedesktop.portal.Desktop", "/org/freedesktop/portal/desktop")
+
+	var value dbus.Variant
+	err = portal.CallWithContext(ctx, "org.freedesktop.portal.Settings.ReadOne", 0,
+		namespace, key).Store(&value)
+	if err != nil {
+		// ReadOne was added in version 2, Read wraps the value once more
+		err = portal.CallWithContext(ctx, "org.freedesktop.portal.Settings.Read", 0,
+			namespace, key).Store(&value)
+		if err != nil {
+			return dbus.Variant{}, false
+		}
+	}
+
+	if inner, ok := value.Value().(dbus.Variant); ok {
+		value = inner
+	}
+	return value, true
+}
@@ -0,0 +1,27 @@
+package xdgicons
+
+// Indexes the directories and parses the index.theme of every given
+// theme and the themes they inherit from, e.g. during idle time at
+// startup, so the first lookups don't have to.
+//
+// If no themes are given, the current theme and fallback theme are used.
+func (il *IconLookup) Preload(themes ...string) {
+	if len(themes) == 0 {
+		themes = []string{il.Theme()}
+		if fallbackTheme := il.FallbackTheme(); fallbackTheme != "" {
+			themes = append(themes, fallbackTheme)
+		}
+	}
+
+	for _, theme := range themes {
+		// loads every theme of the chain as a side effect
+		il.themeChain(theme)
+	}
+}
+
+// Resolves iconNames once, so the directories and files they need are
+// indexed and read into the page cache before the icons are wanted.
+func (il *IconLookup) PreloadIcons(iconNames []string, size int, scale int) {
+	il.Preload()
+	il.FindIcons(iconNames, size, scale)
+}
@@ -0,0 +1,44 @@
+package xdgicons
+
+import (
+	"os"
+	"path"
+
+	"gopkg.in/ini.v1"
+)
+
+// Reads icon_theme from the configuration of qt6ct or qt5ct, the
+// tools Qt applications outside of Plasma are themed with. The one
+// named by QT_QPA_PLATFORMTHEME is read first. Returns "" if none
+// sets it.
+func qtctIconTheme() string {
+	tools := []string{"qt6ct", "qt5ct"}
+	if os.Getenv("QT_QPA_PLATFORMTHEME") == "qt5ct" {
+		tools = []string{"qt5ct", "qt6ct"}
+	}
+
+	for _, configDir := range listConfigDirs() {
+		for _, tool := range tools {
+			cfg, err := ini.LoadSources(ini.LoadOptions{
+				IgnoreInlineComment:     true,
+				SkipUnrecognizableLines: true,
+			}, path.Join(configDir, tool, tool+".conf"))
+			if err != nil {
+				continue
+			}
+			if theme := cfg.Section("Appearance").Key("icon_theme").String(); theme != "" {
+				return theme
+			}
+		}
+	}
+	return ""
+}
+
+// reports whether Qt applications are themed by qt5ct or qt6ct
+func usesQtct() bool {
+	switch os.Getenv("QT_QPA_PLATFORMTHEME") {
+	case "qt5ct", "qt6ct":
+		return true
+	}
+	return false
+}
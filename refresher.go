@@ -0,0 +1,93 @@
+package xdgicons
+
+import (
+	"context"
+	"time"
+)
+
+type backgroundRefresher struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Revalidates the cached base directories at their check interval
+// in a goroutine, so lookups never have to rescan them inline.
+func (il *IconLookup) startRefresher() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &backgroundRefresher{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	il.refresher = r
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(il.refreshInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				il.refreshStale(ctx, now)
+			}
+		}
+	}()
+}
+
+// rescans every base directory whose mtime changed, skipping
+// ones dropped by eviction or invalidation
+func (il *IconLookup) refreshStale(ctx context.Context, now time.Time) {
+	for _, baseDir := range il.getBaseDirs() {
+		if ctx.Err() != nil {
+			return
+		}
+
+		il.mu.RLock()
+		cacheEntry := il.dirCache[baseDir]
+		evicted := il.evictedDirs[baseDir] || il.invalidatedDirs[baseDir]
+		il.mu.RUnlock()
+
+		interval := il.checkInterval(baseDir)
+		if evicted || interval < 0 || il.degraded(baseDir) {
+			continue
+		}
+		// not due yet, e.g. because of its jitter
+		if cacheEntry != nil && now.Sub(time.Unix(0, cacheEntry.lastStat.Load())) < interval+cacheEntry.jitter {
+			continue
+		}
+		if il.shouldRefreshCache(baseDir, cacheEntry, now) {
+			il.refreshBaseDirectory(ctx, baseDir)
+		} else if cacheEntry != nil {
+			cacheEntry.lastStat.Store(now.UnixNano())
+		}
+	}
+}
+
+// Returns the shortest check interval of the base directories, so each
+// is revalidated about on time. Negative if none is ever rechecked.
+func (il *IconLookup) refreshInterval() time.Duration {
+	shortest := il.cacheValidCheckInterval
+	for _, interval := range il.checkIntervals {
+		if interval > 0 && (shortest < 0 || interval < shortest) {
+			shortest = interval
+		}
+	}
+	return shortest
+}
+
+// stops the background refresher, if running, and waits for it to exit
+func (il *IconLookup) stopRefresher() {
+	il.mu.Lock()
+	r := il.refresher
+	il.refresher = nil
+	il.mu.Unlock()
+
+	if r == nil {
+		return
+	}
+	r.cancel()
+	<-r.done
+}
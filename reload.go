@@ -0,0 +1,78 @@
+package xdgicons
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// Drops all cached directory and theme data and rebuilds the cache.
+//
+// If no theme was configured, the default theme is detected again.
+func (il *IconLookup) Reload() {
+	il.mu.RLock()
+	theme, explicit := il.theme, il.explicitTheme
+	il.mu.RUnlock()
+	if !explicit {
+		theme = il.detectTheme()
+	}
+	// the preferred color scheme may have changed as well
+	theme = il.themeVariant(theme)
+
+	il.mu.Lock()
+	switched := theme != il.theme
+	il.theme = theme
+	il.dirCache = make(map[string]*baseDirIconCache)
+	il.dirGeneration++
+	il.clearThemeInfoCache()
+	il.missingThemes = make(map[string]bool)
+	il.loadedThemes = make(map[string]bool)
+	il.evictedDirs = make(map[string]bool)
+	il.invalidatedDirs = make(map[string]bool)
+	il.missingDirs = make(map[string]missingDir)
+	il.mu.Unlock()
+
+	il.createInitialCache()
+
+	if switched {
+		il.notify(ChangeEvent{Kind: ThemeChanged, Theme: theme})
+	}
+}
+
+// Calls [IconLookup.Reload] every time one of sigs is received, so
+// long-running services can be told to pick up newly installed themes.
+//
+// If no signals are given, SIGHUP and SIGUSR1 are used.
+// The returned function stops listening.
+func (il *IconLookup) ReloadOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = defaultReloadSignals
+	}
+	if len(sigs) == 0 {
+		// signal.Notify would relay every signal otherwise
+		return func() {}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		for {
+			select {
+			case <-ch:
+				il.Reload()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package xdgicons
+
+import "os"
+
+var defaultReloadSignals = []os.Signal{}
@@ -0,0 +1,10 @@
+//go:build unix
+
+package xdgicons
+
+import (
+	"os"
+	"syscall"
+)
+
+var defaultReloadSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR1}
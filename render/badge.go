@@ -0,0 +1,182 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Corner of an icon a badge is drawn in
+type BadgePosition int
+
+const (
+	TopRight BadgePosition = iota
+	TopLeft
+	BottomRight
+	BottomLeft
+)
+
+func (p BadgePosition) String() string {
+	switch p {
+	case TopRight:
+		return "TopRight"
+	case TopLeft:
+		return "TopLeft"
+	case BottomRight:
+		return "BottomRight"
+	case BottomLeft:
+		return "BottomLeft"
+	}
+	return "BadgePosition(?)"
+}
+
+// Settings of [DrawBadge]
+type BadgeOptions struct {
+	// Number shown in the badge, e.g. unread messages. Counts
+	// above 99 are shown as "99+".
+	//
+	// If unset, a plain dot is drawn
+	Count int
+
+	// Corner the badge is drawn in
+	//
+	// If unset, uses TopRight
+	Position BadgePosition
+
+	// Fill of the badge
+	//
+	// If unset, uses red (#e01b24)
+	Color color.Color
+
+	// Color of the count
+	//
+	// If unset, uses white
+	TextColor color.Color
+
+	// Size of the count's font in pixels. The badge is sized to fit
+	// it, and dots are as large as badges with a single digit.
+	//
+	// If unset, uses 3/10 of the icon's height
+	FontSize float64
+}
+
+// the font counts are drawn with
+var badgeFont = sync.OnceValues(func() (*opentype.Font, error) {
+	return opentype.Parse(gobold.TTF)
+})
+
+// DrawBadge returns a copy of img with a badge drawn over one of its
+// corners, e.g. for unread counts on taskbar and tray icons.
+func DrawBadge(img image.Image, opts BadgeOptions) (*image.RGBA, error) {
+	bounds := img.Bounds()
+	if opts.Count < 0 {
+		return nil, fmt.Errorf("invalid count %d", opts.Count)
+	}
+	if opts.FontSize < 0 {
+		return nil, fmt.Errorf("invalid font size %v", opts.FontSize)
+	}
+
+	if opts.FontSize == 0 {
+		opts.FontSize = float64(bounds.Dy()) * 0.3
+	}
+	if opts.Color == nil {
+		opts.Color = color.NRGBA{0xe0, 0x1b, 0x24, 0xff}
+	}
+	if opts.TextColor == nil {
+		opts.TextColor = color.White
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(out, out.Bounds(), img, bounds.Min, draw.Src)
+
+	var label string
+	if opts.Count > 99 {
+		label = "99+"
+	} else if opts.Count > 0 {
+		label = strconv.Itoa(opts.Count)
+	}
+
+	var face font.Face
+	if label != "" {
+		f, err := badgeFont()
+		if err != nil {
+			return nil, fmt.Errorf("error loading font: %v", err)
+		}
+		face, err = opentype.NewFace(f, &opentype.FaceOptions{Size: opts.FontSize, DPI: 72})
+		if err != nil {
+			return nil, fmt.Errorf("error loading font: %v", err)
+		}
+		defer face.Close()
+	}
+
+	// a pill around the label, or a circle
+	height := math.Round(opts.FontSize * 1.3)
+	width := height
+	if face != nil {
+		textWidth := float64(font.MeasureString(face, label)) / 64
+		width = max(height, math.Round(textWidth+height/2))
+	}
+
+	var left, top float64
+	switch opts.Position {
+	case TopLeft:
+	case BottomRight:
+		left, top = float64(bounds.Dx())-width, float64(bounds.Dy())-height
+	case BottomLeft:
+		top = float64(bounds.Dy()) - height
+	default:
+		left = float64(bounds.Dx()) - width
+	}
+
+	mask := pillMask(out.Bounds(), left, top, width, height)
+	draw.DrawMask(out, out.Bounds(), image.NewUniform(opts.Color), image.Point{}, mask, image.Point{}, draw.Over)
+
+	if face != nil {
+		// centers the digits, which are as tall as capitals
+		capHeight := float64(face.Metrics().CapHeight) / 64
+		drawer := font.Drawer{
+			Dst:  out,
+			Src:  image.NewUniform(opts.TextColor),
+			Face: face,
+		}
+		advance := float64(drawer.MeasureString(label)) / 64
+		drawer.Dot = fixed.Point26_6{
+			X: fixed.Int26_6((left + (width-advance)/2) * 64),
+			Y: fixed.Int26_6((top + (height+capHeight)/2) * 64),
+		}
+		drawer.DrawString(label)
+	}
+
+	return out, nil
+}
+
+// Returns the antialiased coverage of a pill with fully rounded ends,
+// filling the box at left, top of width×height.
+func pillMask(bounds image.Rectangle, left, top, width, height float64) *image.Alpha {
+	mask := image.NewAlpha(bounds)
+	radius := height / 2
+	// the pill is every point within radius of the segment between
+	// the centers of its ends
+	cy := top + radius
+	x0, x1 := left+radius, left+width-radius
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			px, py := float64(x)+0.5, float64(y)+0.5
+			dx := px - min(max(px, x0), x1)
+			distance := math.Hypot(dx, py-cy) - radius
+			coverage := min(max(0.5-distance, 0), 1)
+			mask.SetAlpha(x, y, color.Alpha{uint8(coverage * 0xff)})
+		}
+	}
+	return mask
+}
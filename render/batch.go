@@ -0,0 +1,54 @@
+package render
+
+import (
+	"image"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// An icon file to render with [RenderMany]
+type RenderRequest struct {
+	// Path of the icon file, e.g. [xdgicons.Icon.Path]
+	Path string
+
+	// Width and height of the rendered image
+	Size int
+
+	Options RenderOptions
+}
+
+// The outcome of a [RenderRequest], as returned by [LoadWithOptions]
+type RenderResult struct {
+	Image image.Image
+	Err   error
+}
+
+// RenderMany renders every request like [LoadWithOptions] does,
+// spreading them over up to GOMAXPROCS goroutines, e.g. for docks
+// that draw dozens of icons at startup. Results are in the order
+// of requests.
+func RenderMany(requests []RenderRequest) []RenderResult {
+	results := make([]RenderResult, len(requests))
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range min(len(requests), runtime.GOMAXPROCS(0)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(requests) {
+					return
+				}
+				request := requests[i]
+				img, err := LoadWithOptions(request.Path, request.Size, request.Options)
+				results[i] = RenderResult{Image: img, Err: err}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,125 @@
+package render
+
+import (
+	"container/list"
+	"image"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// Rendered icons kept in memory, see [RenderOptions.Cache]. Entries are
+// keyed by the icon file, its modification time, the requested size and
+// the options affecting the image, so changed files are rendered again.
+// When full, the least recently used entry is dropped.
+//
+// A Cache is safe for concurrent use.
+type Cache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	// most recently used first
+	order *list.List
+}
+
+type cacheKey struct {
+	path     string
+	mtime    int64
+	fileSize int64
+	size     int
+	filter   Filter
+	padding  int
+	// nil for the default
+	rasterizer SVGRasterizer
+	// the colors of the symbolic classes, empty if not recolored
+	symbolic [4]string
+}
+
+type cacheEntry struct {
+	key cacheKey
+	img image.Image
+}
+
+// Returns an empty cache holding up to maxEntries images.
+// If maxEntries isn't positive, it's unbounded.
+func NewCache(maxEntries int) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		entries:    make(map[cacheKey]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// returns the number of cached images
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// drops every cached image
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[cacheKey]*list.Element)
+	c.order.Init()
+}
+
+// renders the icon like LoadWithOptions, unless it's cached
+func (c *Cache) load(iconPath string, size int, opts RenderOptions) (image.Image, error) {
+	opts.Cache = nil
+
+	// rasterizers are part of the key
+	uncachable := opts.Rasterizer != nil && !reflect.TypeOf(opts.Rasterizer).Comparable()
+
+	stat, err := os.Stat(iconPath)
+	if err != nil || uncachable {
+		return LoadWithOptions(iconPath, size, opts)
+	}
+
+	key := cacheKey{
+		path:     iconPath,
+		mtime:    stat.ModTime().UnixNano(),
+		fileSize: stat.Size(),
+		size:     size,
+		filter:   opts.Filter,
+		padding:  opts.Padding,
+
+		rasterizer: opts.Rasterizer,
+	}
+	if opts.Symbolic != nil && isSymbolicSVG(iconPath) {
+		for i, class := range []string{"foreground", "success", "warning", "error"} {
+			key.symbolic[i] = opts.Symbolic.role(class)
+		}
+	}
+
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		img := element.Value.(*cacheEntry).img
+		c.mu.Unlock()
+		return img, nil
+	}
+	c.mu.Unlock()
+
+	img, err := LoadWithOptions(iconPath, size, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		// rendered concurrently
+		c.order.MoveToFront(element)
+		return element.Value.(*cacheEntry).img, nil
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, img: img})
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	return img, nil
+}
@@ -0,0 +1,57 @@
+package render
+
+import (
+	"image"
+	"image/color"
+)
+
+// CompareIcons measures how different two images are.
+//
+// The score ranges from 0 (identical) to 1 (every channel of every pixel
+// maximally different). The diff image has the size of a and shows a faded
+// grayscale copy of a with differing pixels highlighted in red, more
+// opaque the larger the difference.
+//
+// b is scaled to the size of a if their sizes differ.
+func CompareIcons(a, b image.Image) (float64, *image.RGBA) {
+	bounds := a.Bounds()
+	b = scaleImage(b, bounds.Dx(), bounds.Dy())
+	bBounds := b.Bounds()
+
+	diff := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	if bounds.Empty() {
+		return 0, diff
+	}
+
+	var total float64
+	for y := range bounds.Dy() {
+		for x := range bounds.Dx() {
+			ca := color.RGBA64Model.Convert(a.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.RGBA64)
+			cb := color.RGBA64Model.Convert(b.At(bBounds.Min.X+x, bBounds.Min.Y+y)).(color.RGBA64)
+
+			delta := (absDiff(ca.R, cb.R) + absDiff(ca.G, cb.G) + absDiff(ca.B, cb.B) + absDiff(ca.A, cb.A)) / (4 * 0xffff)
+			total += delta
+
+			if delta > 0 {
+				// scale small differences up so they stay visible
+				alpha := uint8(min(255, 64+delta*191*4))
+				diff.SetRGBA(x, y, color.RGBA{alpha, 0, 0, alpha})
+				continue
+			}
+
+			// both are premultiplied, so fading keeps them consistent
+			shade := color.GrayModel.Convert(ca).(color.Gray).Y / 4
+			faded := uint8(ca.A>>8) / 4
+			diff.SetRGBA(x, y, color.RGBA{shade, shade, shade, faded})
+		}
+	}
+
+	return total / float64(bounds.Dx()*bounds.Dy()), diff
+}
+
+func absDiff(a, b uint16) float64 {
+	if a > b {
+		return float64(a - b)
+	}
+	return float64(b - a)
+}
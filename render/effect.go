@@ -0,0 +1,83 @@
+package render
+
+import (
+	"image"
+	"image/color"
+)
+
+// Look applied to an icon by [ApplyEffect], e.g. for the state of the
+// widget showing it. Effects can be combined, like
+// EffectGrayscale|EffectHighlight.
+type Effect int
+
+const (
+	// Desaturated and half transparent, the "insensitive" look of
+	// icons in disabled buttons and menu items
+	EffectDisabled Effect = 1 << iota
+
+	// Desaturated, keeping its transparency
+	EffectGrayscale
+
+	// Brightened, as toolkits draw icons under the pointer
+	EffectHighlight
+)
+
+func (e Effect) String() string {
+	if e == 0 {
+		return "Effect(0)"
+	}
+
+	names := []string{"EffectDisabled", "EffectGrayscale", "EffectHighlight"}
+	s := ""
+	for i, name := range names {
+		if e&(1<<i) == 0 {
+			continue
+		}
+		if s != "" {
+			s += "|"
+		}
+		s += name
+	}
+	if e&^(1<<len(names)-1) != 0 {
+		return "Effect(?)"
+	}
+	return s
+}
+
+// how much of the way to white highlighted pixels are moved
+const highlightAmount = 0.15
+
+// ApplyEffect returns a copy of img with effect applied,
+// so disabled and hovered states don't need icons of their own.
+func ApplyEffect(img image.Image, effect Effect) *image.RGBA {
+	bounds := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	for y := range bounds.Dy() {
+		for x := range bounds.Dx() {
+			px := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+
+			if effect&(EffectDisabled|EffectGrayscale) != 0 {
+				// Rec. 601 luma, like GTK uses
+				luma := uint8((299*int(px.R) + 587*int(px.G) + 114*int(px.B) + 500) / 1000)
+				px.R, px.G, px.B = luma, luma, luma
+			}
+			if effect&EffectHighlight != 0 {
+				px.R = lighten(px.R)
+				px.G = lighten(px.G)
+				px.B = lighten(px.B)
+			}
+			if effect&EffectDisabled != 0 {
+				px.A /= 2
+			}
+
+			out.Set(x, y, px)
+		}
+	}
+	return out
+}
+
+// moves the channel highlightAmount of the way to full intensity
+func lighten(channel uint8) uint8 {
+	return channel + uint8(float64(0xff-channel)*highlightAmount+0.5)
+}
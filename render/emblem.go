@@ -0,0 +1,55 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+
+	"github.com/codelif/xdgicons"
+)
+
+// ComposeWithEmblems renders base at size×size and draws the emblems
+// (e.g. "emblem-symbolic-link", "emblem-readonly"), resolved with finder,
+// over its corners at half the size, as file managers do. The first emblem
+// goes to the bottom right corner, then bottom left, top left and top right.
+//
+// Emblems that cannot be found, and any beyond the fourth, are left out.
+func ComposeWithEmblems(finder xdgicons.Finder, base xdgicons.Icon, emblems []string, size int) (*image.RGBA, error) {
+	img, err := Load(base.Path, size)
+	if err != nil {
+		return nil, fmt.Errorf("error loading %q: %v", base.Path, err)
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	emblemSize := max(1, size/2)
+	corners := []image.Point{
+		{size - emblemSize, size - emblemSize},
+		{0, size - emblemSize},
+		{0, 0},
+		{size - emblemSize, 0},
+	}
+
+	corner := 0
+	for _, name := range emblems {
+		if corner == len(corners) {
+			break
+		}
+
+		icon, err := finder.FindIcon(name, emblemSize, 1)
+		if err != nil {
+			continue
+		}
+		emblem, err := Load(icon.Path, emblemSize)
+		if err != nil {
+			return nil, fmt.Errorf("error loading %q: %v", icon.Path, err)
+		}
+
+		at := corners[corner]
+		draw.Draw(out, image.Rect(at.X, at.Y, at.X+emblemSize, at.Y+emblemSize), emblem, emblem.Bounds().Min, draw.Over)
+		corner++
+	}
+
+	return out, nil
+}
@@ -0,0 +1,105 @@
+package render
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+
+	"golang.org/x/image/bmp"
+)
+
+// sizes stored in ICO files, up to the size of the image
+var icoSizes = []int{16, 24, 32, 48, 64, 128, 256}
+
+// EncodeImage writes img to w in format, which can't be Original.
+//
+// ICO files hold img at the common Windows icon sizes up to its own,
+// each encoded as PNG. Images that aren't square are centered in each
+// entry, keeping their aspect ratio. WebP files are lossless.
+func EncodeImage(w io.Writer, img image.Image, format Format) error {
+	switch format {
+	case PNG:
+		return png.Encode(w, img)
+	case ICO:
+		return encodeICO(w, img)
+	case BMP:
+		return bmp.Encode(w, img)
+	case WebP:
+		return encodeWebP(w, img)
+	case QOI:
+		return encodeQOI(w, img)
+	}
+	return fmt.Errorf("unsupported format %v", format)
+}
+
+// SaveImage writes img to the file at imagePath in format,
+// like [EncodeImage] does.
+func SaveImage(img image.Image, imagePath string, format Format) error {
+	f, err := os.Create(imagePath)
+	if err != nil {
+		return fmt.Errorf("error writing image: %v", err)
+	}
+	defer f.Close()
+
+	if err := EncodeImage(f, img, format); err != nil {
+		return fmt.Errorf("error writing image: %v", err)
+	}
+	return f.Close()
+}
+
+// Encodes img as an ICO file with PNG entries, which every
+// Windows since Vista reads, for each of icoSizes that fits.
+func encodeICO(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	largest := max(bounds.Dx(), bounds.Dy())
+	if largest < 1 {
+		return fmt.Errorf("invalid size %dx%d for ico", bounds.Dx(), bounds.Dy())
+	}
+
+	var sizes []int
+	for _, size := range icoSizes {
+		if size <= largest {
+			sizes = append(sizes, size)
+		}
+	}
+	if len(sizes) == 0 {
+		sizes = []int{largest}
+	}
+
+	var entries [][]byte
+	for _, size := range sizes {
+		var entry bytes.Buffer
+		if err := png.Encode(&entry, fitImage(img, size, 0, CatmullRom)); err != nil {
+			return err
+		}
+		entries = append(entries, entry.Bytes())
+	}
+
+	// ICONDIR, then an ICONDIRENTRY per image, then the images
+	out := make([]byte, 0, 6+16*len(entries))
+	out = binary.LittleEndian.AppendUint16(out, 0)
+	out = binary.LittleEndian.AppendUint16(out, 1)
+	out = binary.LittleEndian.AppendUint16(out, uint16(len(entries)))
+
+	offset := 6 + 16*len(entries)
+	for i, entry := range entries {
+		// 0 stands for 256
+		dimension := byte(sizes[i] % 256)
+		out = append(out, dimension, dimension, 0, 0)
+		out = binary.LittleEndian.AppendUint16(out, 1)
+		out = binary.LittleEndian.AppendUint16(out, 32)
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(entry)))
+		out = binary.LittleEndian.AppendUint32(out, uint32(offset))
+		offset += len(entry)
+	}
+	for _, entry := range entries {
+		out = append(out, entry...)
+	}
+
+	_, err := w.Write(out)
+	return err
+}
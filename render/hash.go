@@ -0,0 +1,168 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/bits"
+	"slices"
+
+	"github.com/codelif/xdgicons"
+)
+
+// size icons are rasterized at before hashing
+const hashRenderSize = 64
+
+// DHash computes a 64-bit difference hash of img.
+//
+// The image is reduced to 9x8 grayscale and every bit records
+// whether a pixel is brighter than its right neighbour.
+func DHash(img image.Image) uint64 {
+	gray := grayscale(img, 9, 8)
+
+	var hash uint64
+	for y := range 8 {
+		for x := range 8 {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// PHash computes a 64-bit perceptual hash of img.
+//
+// The image is reduced to 32x32 grayscale, transformed with a DCT and
+// every bit of the hash records whether one of the 8x8 lowest frequencies
+// is above their median.
+func PHash(img image.Image) uint64 {
+	const n = 32
+	gray := grayscale(img, n, n)
+
+	var coefficients [8][8]float64
+	for u := range 8 {
+		for v := range 8 {
+			var sum float64
+			for y := range n {
+				for x := range n {
+					sum += gray[y][x] *
+						math.Cos(float64(2*x+1)*float64(u)*math.Pi/(2*n)) *
+						math.Cos(float64(2*y+1)*float64(v)*math.Pi/(2*n))
+				}
+			}
+			coefficients[v][u] = sum
+		}
+	}
+
+	// the DC term (0,0) carries the average brightness only, skip it
+	values := make([]float64, 0, 63)
+	for v := range 8 {
+		for u := range 8 {
+			if u == 0 && v == 0 {
+				continue
+			}
+			values = append(values, coefficients[v][u])
+		}
+	}
+	median := medianOf(values)
+
+	var hash uint64
+	for v := range 8 {
+		for u := range 8 {
+			hash <<= 1
+			if coefficients[v][u] > median {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// HammingDistance returns the number of differing bits between two hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// DedupeIcons renders every icon and groups the ones that look identical.
+//
+// Two icons are considered identical if the hamming distance between their
+// perceptual hashes is at most threshold (0 for exact hash matches, values
+// around 4 tolerate antialiasing differences between sizes).
+// Only groups with at least two icons are returned.
+func DedupeIcons(icons []xdgicons.Icon, threshold int) ([][]xdgicons.Icon, error) {
+	hashes := make([]uint64, len(icons))
+	for i, icon := range icons {
+		img, err := Load(icon.Path, hashRenderSize)
+		if err != nil {
+			return nil, fmt.Errorf("error loading %q: %v", icon.Path, err)
+		}
+		hashes[i] = PHash(img)
+	}
+
+	grouped := make([]bool, len(icons))
+	var groups [][]xdgicons.Icon
+	for i := range icons {
+		if grouped[i] {
+			continue
+		}
+
+		group := []xdgicons.Icon{icons[i]}
+		for j := i + 1; j < len(icons); j++ {
+			if !grouped[j] && HammingDistance(hashes[i], hashes[j]) <= threshold {
+				group = append(group, icons[j])
+				grouped[j] = true
+			}
+		}
+
+		if len(group) > 1 {
+			groups = append(groups, group)
+		}
+	}
+
+	return groups, nil
+}
+
+// box-filters img down to w×h and converts it to luminance, with
+// transparent pixels composited over black
+func grayscale(img image.Image, w, h int) [][]float64 {
+	bounds := img.Bounds()
+	gray := make([][]float64, h)
+	for y := range h {
+		gray[y] = make([]float64, w)
+		y0 := bounds.Min.Y + y*bounds.Dy()/h
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/h)
+		for x := range w {
+			x0 := bounds.Min.X + x*bounds.Dx()/w
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/w)
+
+			var sum float64
+			for srcY := y0; srcY < y1; srcY++ {
+				for srcX := x0; srcX < x1; srcX++ {
+					c := color.NRGBA64Model.Convert(img.At(srcX, srcY)).(color.NRGBA64)
+					alpha := float64(c.A) / 0xffff
+					sum += (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) * alpha / 0xffff
+				}
+			}
+			gray[y][x] = sum / float64((x1-x0)*(y1-y0))
+		}
+	}
+
+	return gray
+}
+
+func medianOf(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	slices.Sort(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,86 @@
+package render
+
+import (
+	"image"
+	"image/color"
+)
+
+// Byte order of the 32-bit pixels of a [PixelBuffer]
+type PixelFormat int
+
+const (
+	// A, R, G, B bytes, i.e. ARGB32 in network byte order, as used
+	// by the IconPixmap property of StatusNotifierItem
+	ARGB32 PixelFormat = iota
+
+	// B, G, R, A bytes, i.e. ARGB32 in little-endian byte order,
+	// as used by wl_shm's ARGB8888 and by _NET_WM_ICON on
+	// little-endian X servers
+	BGRA32
+)
+
+func (f PixelFormat) String() string {
+	switch f {
+	case ARGB32:
+		return "ARGB32"
+	case BGRA32:
+		return "BGRA32"
+	}
+	return "PixelFormat(?)"
+}
+
+// Raw pixels of an image, as handed to trays and compositors
+type PixelBuffer struct {
+	Width  int
+	Height int
+
+	// Bytes from the start of a row to the start of the next one
+	Stride int
+
+	Format PixelFormat
+
+	// Whether the colors are multiplied by alpha, as wl_shm buffers
+	// expect. StatusNotifierItem and _NET_WM_ICON expect them not to be.
+	Premultiplied bool
+
+	// The pixels, row by row, 4 bytes each
+	Pix []byte
+}
+
+// Pixels converts img to 32-bit pixels in the byte order of format,
+// multiplying the colors by alpha if premultiplied is set.
+func Pixels(img image.Image, format PixelFormat, premultiplied bool) PixelBuffer {
+	bounds := img.Bounds()
+	buf := PixelBuffer{
+		Width:         bounds.Dx(),
+		Height:        bounds.Dy(),
+		Stride:        bounds.Dx() * 4,
+		Format:        format,
+		Premultiplied: premultiplied,
+	}
+	buf.Pix = make([]byte, buf.Stride*buf.Height)
+
+	for y := range buf.Height {
+		row := buf.Pix[y*buf.Stride:]
+		for x := range buf.Width {
+			c := img.At(bounds.Min.X+x, bounds.Min.Y+y)
+			var r, g, b, a uint8
+			if premultiplied {
+				rgba := color.RGBAModel.Convert(c).(color.RGBA)
+				r, g, b, a = rgba.R, rgba.G, rgba.B, rgba.A
+			} else {
+				nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+				r, g, b, a = nrgba.R, nrgba.G, nrgba.B, nrgba.A
+			}
+
+			pixel := row[x*4 : x*4+4]
+			switch format {
+			case BGRA32:
+				pixel[0], pixel[1], pixel[2], pixel[3] = b, g, r, a
+			default:
+				pixel[0], pixel[1], pixel[2], pixel[3] = a, r, g, b
+			}
+		}
+	}
+	return buf
+}
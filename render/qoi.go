@@ -0,0 +1,80 @@
+package render
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+)
+
+// QOI chunk tags
+const (
+	qoiOpIndex = 0x00
+	qoiOpDiff  = 0x40
+	qoiOpLuma  = 0x80
+	qoiOpRun   = 0xc0
+	qoiOpRGB   = 0xfe
+	qoiOpRGBA  = 0xff
+)
+
+// encodes img in the Quite OK Image format, see https://qoiformat.org
+func encodeQOI(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+
+	out := make([]byte, 0, 14+bounds.Dx()*bounds.Dy()*2+8)
+	out = append(out, "qoif"...)
+	out = binary.BigEndian.AppendUint32(out, uint32(bounds.Dx()))
+	out = binary.BigEndian.AppendUint32(out, uint32(bounds.Dy()))
+	// RGBA, sRGB with linear alpha
+	out = append(out, 4, 0)
+
+	var index [64]color.NRGBA
+	prev := color.NRGBA{A: 0xff}
+	run := 0
+	total := bounds.Dx() * bounds.Dy()
+	for i := range total {
+		x, y := bounds.Min.X+i%bounds.Dx(), bounds.Min.Y+i/bounds.Dx()
+		px := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+
+		if px == prev {
+			run++
+			if run == 62 || i == total-1 {
+				out = append(out, qoiOpRun|byte(run-1))
+				run = 0
+			}
+			continue
+		}
+		if run > 0 {
+			out = append(out, qoiOpRun|byte(run-1))
+			run = 0
+		}
+
+		hash := (int(px.R)*3 + int(px.G)*5 + int(px.B)*7 + int(px.A)*11) % 64
+		switch {
+		case index[hash] == px:
+			out = append(out, qoiOpIndex|byte(hash))
+		case px.A != prev.A:
+			index[hash] = px
+			out = append(out, qoiOpRGBA, px.R, px.G, px.B, px.A)
+		default:
+			index[hash] = px
+			dr := int(int8(px.R - prev.R))
+			dg := int(int8(px.G - prev.G))
+			db := int(int8(px.B - prev.B))
+			drg, dbg := dr-dg, db-dg
+			switch {
+			case dr >= -2 && dr <= 1 && dg >= -2 && dg <= 1 && db >= -2 && db <= 1:
+				out = append(out, qoiOpDiff|byte(dr+2)<<4|byte(dg+2)<<2|byte(db+2))
+			case dg >= -32 && dg <= 31 && drg >= -8 && drg <= 7 && dbg >= -8 && dbg <= 7:
+				out = append(out, qoiOpLuma|byte(dg+32), byte(drg+8)<<4|byte(dbg+8))
+			default:
+				out = append(out, qoiOpRGB, px.R, px.G, px.B)
+			}
+		}
+		prev = px
+	}
+
+	out = append(out, 0, 0, 0, 0, 0, 0, 0, 1)
+	_, err := w.Write(out)
+	return err
+}
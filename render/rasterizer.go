@@ -0,0 +1,42 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+)
+
+// Renders svg documents, see [RenderOptions.Rasterizer]. Lets
+// librsvg or resvg be used for icons oksvg doesn't render correctly,
+// e.g. ones with filters, masks or text.
+type SVGRasterizer interface {
+	// Renders the svg document in data centered in a size×size
+	// image, scaled to fit while keeping its aspect ratio
+	Rasterize(data []byte, size int) (image.Image, error)
+}
+
+// The default [SVGRasterizer], using oksvg and rasterx
+type OksvgRasterizer struct{}
+
+func (OksvgRasterizer) Rasterize(data []byte, size int) (image.Image, error) {
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(data), oksvg.IgnoreErrorMode)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing svg: %v", err)
+	}
+
+	width, height := float64(size), float64(size)
+	if viewBox := icon.ViewBox; viewBox.W > 0 && viewBox.H > 0 {
+		scale := min(float64(size)/viewBox.W, float64(size)/viewBox.H)
+		width, height = viewBox.W*scale, viewBox.H*scale
+	}
+	icon.SetTarget((float64(size)-width)/2, (float64(size)-height)/2, width, height)
+
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	scanner := rasterx.NewScannerGV(size, size, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(size, size, scanner), 1)
+
+	return img, nil
+}
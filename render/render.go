@@ -0,0 +1,264 @@
+// Package render decodes and rasterizes icon files found by xdgicons
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// Resampling filter used to scale raster icons
+type Filter int
+
+const (
+	// Sharp and smooth, a good default for icons
+	CatmullRom Filter = iota
+
+	// Fastest, but jagged when scaling down by much
+	NearestNeighbor
+
+	// Fast and smooth, but blurry
+	Bilinear
+
+	// Sharpest, and the slowest
+	Lanczos
+)
+
+func (f Filter) String() string {
+	switch f {
+	case CatmullRom:
+		return "CatmullRom"
+	case NearestNeighbor:
+		return "NearestNeighbor"
+	case Bilinear:
+		return "Bilinear"
+	case Lanczos:
+		return "Lanczos"
+	}
+	return "Filter(?)"
+}
+
+// Settings of [LoadWithOptions] and [RenderIcon]
+type RenderOptions struct {
+	// How raster icons are scaled to the requested size.
+	// svg icons are always rendered at that size.
+	//
+	// If unset, uses CatmullRom
+	Filter Filter
+
+	// Colors symbolic svg icons (whose name ends in -symbolic)
+	// are drawn with, as GTK does. Other icons keep their colors.
+	//
+	// If unset, symbolic icons are drawn as they are
+	Symbolic *SymbolicColors
+
+	// Renderer of svg icons. Rasterizers that aren't comparable,
+	// e.g. structs holding a slice, bypass the Cache.
+	//
+	// If unset, uses [OksvgRasterizer]
+	Rasterizer SVGRasterizer
+
+	// Pixels left transparent on every side of the icon. Icons are
+	// scaled to fit the rest, keeping their aspect ratio, and centered.
+	Padding int
+
+	// Cache renders are taken from and added to, e.g. for notification
+	// daemons drawing the same few icons over and over. The cached images
+	// are shared and must not be modified.
+	//
+	// If unset, icons are rendered every time
+	Cache *Cache
+
+	// Format written by [RenderIcon]
+	//
+	// If unset, uses PNG
+	Format Format
+}
+
+// Load decodes the icon file at iconPath and scales it to fit a size×size
+// image, keeping its aspect ratio.
+//
+// png, svg and xpm files are supported, as are jpeg, gif and webp
+// files some pixmaps come as. Only the first frame of a gif is used.
+func Load(iconPath string, size int) (image.Image, error) {
+	return LoadWithOptions(iconPath, size, RenderOptions{})
+}
+
+// Like [Load], scaling raster icons as set by opts
+func LoadWithOptions(iconPath string, size int, opts RenderOptions) (image.Image, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid size %d", size)
+	}
+	if opts.Padding < 0 || 2*opts.Padding >= size {
+		return nil, fmt.Errorf("invalid padding %d for size %d", opts.Padding, size)
+	}
+	if opts.Cache != nil {
+		return opts.Cache.load(iconPath, size, opts)
+	}
+
+	data, err := os.ReadFile(iconPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %v", err)
+	}
+
+	switch strings.ToLower(strings.TrimPrefix(path.Ext(iconPath), ".")) {
+	case "svg":
+		if opts.Symbolic != nil && isSymbolicSVG(iconPath) {
+			data, err = recolorSymbolic(data, *opts.Symbolic)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing svg: %v", err)
+			}
+		}
+		return renderSVG(data, size, opts.Padding, opts.Rasterizer)
+	case "png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding png: %v", err)
+		}
+		return fitImage(img, size, opts.Padding, opts.Filter), nil
+	case "xpm":
+		img, err := decodeXPM(data)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding xpm: %v", err)
+		}
+		return fitImage(img, size, opts.Padding, opts.Filter), nil
+	case "jpg", "jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding jpeg: %v", err)
+		}
+		return fitImage(img, size, opts.Padding, opts.Filter), nil
+	case "gif":
+		img, err := gif.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding gif: %v", err)
+		}
+		return fitImage(img, size, opts.Padding, opts.Filter), nil
+	case "webp":
+		img, err := webp.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding webp: %v", err)
+		}
+		return fitImage(img, size, opts.Padding, opts.Filter), nil
+	}
+
+	return nil, fmt.Errorf("unsupported icon format %q", path.Ext(iconPath))
+}
+
+// renders the svg with rasterizer, leaving padding pixels around it
+func renderSVG(data []byte, size, padding int, rasterizer SVGRasterizer) (image.Image, error) {
+	if rasterizer == nil {
+		rasterizer = OksvgRasterizer{}
+	}
+
+	inner := size - 2*padding
+	img, err := rasterizer.Rasterize(data, inner)
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	if padding == 0 && bounds.Dx() == size && bounds.Dy() == size {
+		return img, nil
+	}
+
+	// also centers images of the wrong size
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	offset := image.Pt((size-bounds.Dx())/2, (size-bounds.Dy())/2)
+	draw.Draw(dst, bounds.Sub(bounds.Min).Add(offset), img, bounds.Min, draw.Src)
+	return dst, nil
+}
+
+// Scales src with filter to fit in a size×size image, keeping its
+// aspect ratio and leaving padding pixels around it, and centers it.
+func fitImage(src image.Image, size, padding int, filter Filter) image.Image {
+	bounds := src.Bounds()
+	if padding == 0 && bounds.Dx() == bounds.Dy() {
+		return resizeImage(src, size, size, filter)
+	}
+	if bounds.Empty() {
+		return image.NewRGBA(image.Rect(0, 0, size, size))
+	}
+
+	inner := size - 2*padding
+	width, height := inner, inner
+	if bounds.Dx() > bounds.Dy() {
+		height = max(1, int(math.Round(float64(inner*bounds.Dy())/float64(bounds.Dx()))))
+	} else {
+		width = max(1, int(math.Round(float64(inner*bounds.Dx())/float64(bounds.Dy()))))
+	}
+	scaled := resizeImage(src, width, height, filter)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	offset := image.Pt((size-width)/2, (size-height)/2)
+	draw.Draw(dst, scaled.Bounds().Sub(scaled.Bounds().Min).Add(offset), scaled, scaled.Bounds().Min, draw.Src)
+	return dst
+}
+
+// scales src to a width×height image with filter
+func resizeImage(src image.Image, width, height int, filter Filter) image.Image {
+	bounds := src.Bounds()
+	if filter == NearestNeighbor || (bounds.Dx() == width && bounds.Dy() == height) {
+		return scaleImage(src, width, height)
+	}
+
+	var scaler draw.Scaler
+	switch filter {
+	case Bilinear:
+		scaler = draw.BiLinear
+	case Lanczos:
+		scaler = lanczos3
+	default:
+		scaler = draw.CatmullRom
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	scaler.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	return dst
+}
+
+// the Lanczos kernel with a = 3
+var lanczos3 = &draw.Kernel{
+	Support: 3,
+	At: func(t float64) float64 {
+		if t == 0 {
+			return 1
+		}
+		if t < 0 {
+			t = -t
+		}
+		if t >= 3 {
+			return 0
+		}
+		x := math.Pi * t
+		return 3 * math.Sin(x) * math.Sin(x/3) / (x * x)
+	},
+}
+
+// nearest-neighbour scaling, good enough for comparing
+func scaleImage(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	if bounds.Dx() == width && bounds.Dy() == height {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		srcY := bounds.Min.Y + y*bounds.Dy()/height
+		for x := range width {
+			srcX := bounds.Min.X + x*bounds.Dx()/width
+			dst.Set(x, y, src.At(srcX, srcY))
+		}
+	}
+
+	return dst
+}
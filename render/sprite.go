@@ -0,0 +1,330 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/codelif/xdgicons"
+)
+
+// Position of an icon inside a sprite sheet
+type SpriteEntry struct {
+	// Icon name the entry was resolved from
+	Name string `json:"name"`
+
+	// Path of the source icon file
+	Path string `json:"path"`
+
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Icons packed into a single image
+type SpriteSheet struct {
+	Image   *image.RGBA
+	Entries []SpriteEntry
+
+	// Names that could not be resolved and were left out
+	Missing []string
+}
+
+// BuildSpriteSheet resolves names with finder and packs the icons into a grid
+// of size*scale pixel cells.
+//
+// Names that cannot be found are skipped and listed in Missing.
+func BuildSpriteSheet(finder xdgicons.Finder, names []string, size, scale int) (*SpriteSheet, error) {
+	cell := size * scale
+	if cell <= 0 {
+		return nil, fmt.Errorf("invalid size %d@%d", size, scale)
+	}
+
+	sheet := &SpriteSheet{}
+	var images []image.Image
+	for _, name := range names {
+		icon, err := finder.FindIcon(name, size, scale)
+		if err != nil {
+			sheet.Missing = append(sheet.Missing, name)
+			continue
+		}
+
+		img, err := Load(icon.Path, cell)
+		if err != nil {
+			return nil, fmt.Errorf("error loading %q: %v", icon.Path, err)
+		}
+
+		images = append(images, img)
+		sheet.Entries = append(sheet.Entries, SpriteEntry{
+			Name:   name,
+			Path:   icon.Path,
+			Width:  cell,
+			Height: cell,
+		})
+	}
+
+	columns := max(1, int(math.Ceil(math.Sqrt(float64(len(images))))))
+	rows := (len(images) + columns - 1) / columns
+	sheet.Image = image.NewRGBA(image.Rect(0, 0, columns*cell, rows*cell))
+
+	for i, img := range images {
+		entry := &sheet.Entries[i]
+		entry.X = (i % columns) * cell
+		entry.Y = (i / columns) * cell
+		draw.Draw(sheet.Image, image.Rect(entry.X, entry.Y, entry.X+cell, entry.Y+cell), img, img.Bounds().Min, draw.Src)
+	}
+
+	return sheet, nil
+}
+
+// WritePNG encodes the sheet image as PNG
+func (s *SpriteSheet) WritePNG(w io.Writer) error {
+	return png.Encode(w, s.Image)
+}
+
+// WriteJSON writes the entry coordinates as a JSON array
+func (s *SpriteSheet) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s.Entries)
+}
+
+// WriteCSS writes one class per icon, named classPrefix followed by the
+// icon name, using imageURL as the background image.
+func (s *SpriteSheet) WriteCSS(w io.Writer, imageURL, classPrefix string) error {
+	_, err := fmt.Fprintf(w, "[class^=%q], [class*=\" %s\"] {\n  background-image: url(%q);\n  background-repeat: no-repeat;\n  display: inline-block;\n}\n",
+		classPrefix, classPrefix, imageURL)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range s.Entries {
+		_, err := fmt.Fprintf(w, "\n.%s {\n  width: %dpx;\n  height: %dpx;\n  background-position: %dpx %dpx;\n}\n",
+			cssEscape(classPrefix+entry.Name), entry.Width, entry.Height, -entry.X, -entry.Y)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteSVGSymbols resolves names with finder and writes an SVG document
+// containing one <symbol> per icon, with the icon name as id, for use
+// with <svg><use href="sheet.svg#name"/></svg>.
+//
+// SVG icons are inlined, raster icons are embedded as data URIs.
+// Names that cannot be found are skipped and returned.
+func WriteSVGSymbols(w io.Writer, finder xdgicons.Finder, names []string, size, scale int) ([]string, error) {
+	var missing []string
+	var buf bytes.Buffer
+	buf.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" style="display:none">` + "\n")
+
+	for _, name := range names {
+		icon, err := finder.FindIcon(name, size, scale)
+		if err != nil {
+			missing = append(missing, name)
+			continue
+		}
+
+		data, err := os.ReadFile(icon.Path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading file: %v", err)
+		}
+
+		id := xmlEscape(name)
+		if strings.EqualFold(path.Ext(icon.Path), ".svg") {
+			viewBox, inner, err := splitSVG(data, name+"__")
+			if err != nil {
+				return nil, fmt.Errorf("error parsing %q: %v", icon.Path, err)
+			}
+			fmt.Fprintf(&buf, "<symbol id=\"%s\" viewBox=\"%s\">%s</symbol>\n", id, xmlEscape(viewBox), inner)
+			continue
+		}
+
+		cell := size * scale
+		img, err := Load(icon.Path, cell)
+		if err != nil {
+			return nil, fmt.Errorf("error loading %q: %v", icon.Path, err)
+		}
+		var encoded bytes.Buffer
+		if err := png.Encode(&encoded, img); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "<symbol id=\"%s\" viewBox=\"0 0 %d %d\"><image width=\"%d\" height=\"%d\" xlink:href=\"data:image/png;base64,%s\"/></symbol>\n",
+			id, cell, cell, cell, cell, base64.StdEncoding.EncodeToString(encoded.Bytes()))
+	}
+
+	buf.WriteString("</svg>\n")
+	_, err := w.Write(buf.Bytes())
+	return missing, err
+}
+
+// Returns the viewBox of the root <svg> element and its inner markup,
+// made fit to be one of several symbols in a document: ids and the
+// references to them get idPrefix, so symbols don't pick up each
+// other's gradients, and elements and attributes of other namespaces
+// (e.g. inkscape: or sodipodi:) are dropped, since their prefixes are
+// declared on the root element. Comments and instructions are dropped.
+func splitSVG(data []byte, idPrefix string) (string, []byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+
+	var inner bytes.Buffer
+	depth := 0
+	viewBox := ""
+	// depth of the foreign element being skipped, 0 if none
+	skipping := 0
+	inStyle := false
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			return "", nil, errors.New("root element isn't closed")
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 1 {
+				if t.Name.Local != "svg" {
+					return "", nil, fmt.Errorf("root element is <%s>, not <svg>", t.Name.Local)
+				}
+				viewBox = svgViewBox(t.Attr)
+				continue
+			}
+			if skipping > 0 {
+				continue
+			}
+			if t.Name.Space != "" {
+				skipping = depth
+				continue
+			}
+
+			inStyle = t.Name.Local == "style"
+			inner.WriteByte('<')
+			inner.WriteString(t.Name.Local)
+			for _, attr := range t.Attr {
+				value, ok := symbolAttr(attr, idPrefix)
+				if ok {
+					fmt.Fprintf(&inner, " %s=\"%s\"", rawName(attr.Name), xmlEscape(value))
+				}
+			}
+			inner.WriteByte('>')
+		case xml.EndElement:
+			depth--
+			if depth == 0 {
+				return viewBox, inner.Bytes(), nil
+			}
+			if skipping > 0 {
+				if depth < skipping {
+					skipping = 0
+				}
+				continue
+			}
+			inStyle = false
+			fmt.Fprintf(&inner, "</%s>", t.Name.Local)
+		case xml.CharData:
+			if depth == 0 || skipping > 0 {
+				continue
+			}
+			text := string(t)
+			if inStyle {
+				text = prefixURLRefs(text, idPrefix)
+			}
+			inner.WriteString(xmlEscape(text))
+		}
+	}
+}
+
+// references to ids in attribute values and stylesheets, e.g. url(#a) or url('#a')
+var urlRefPattern = regexp.MustCompile(`url\(\s*['"]?#`)
+
+func prefixURLRefs(s, idPrefix string) string {
+	return urlRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		return ref + idPrefix
+	})
+}
+
+// returns the value of attr inside a symbol with idPrefix,
+// or false if it has to be dropped
+func symbolAttr(attr xml.Attr, idPrefix string) (string, bool) {
+	switch attr.Name.Space {
+	case "":
+		if attr.Name.Local == "xmlns" {
+			return "", false
+		}
+	case "xlink", "xml":
+	default:
+		return "", false
+	}
+
+	switch {
+	case attr.Name.Space == "" && attr.Name.Local == "id":
+		return idPrefix + attr.Value, true
+	case attr.Name.Local == "href" && strings.HasPrefix(attr.Value, "#"):
+		return "#" + idPrefix + attr.Value[1:], true
+	}
+	return prefixURLRefs(attr.Value, idPrefix), true
+}
+
+func svgViewBox(attrs []xml.Attr) string {
+	var width, height string
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "viewBox":
+			return attr.Value
+		case "width":
+			width = strings.TrimSuffix(attr.Value, "px")
+		case "height":
+			height = strings.TrimSuffix(attr.Value, "px")
+		}
+	}
+
+	if width != "" && height != "" {
+		return "0 0 " + width + " " + height
+	}
+	return "0 0 16 16"
+}
+
+func xmlEscape(s string) string {
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(s))
+	return strings.ReplaceAll(buf.String(), `"`, "&#34;")
+}
+
+// escapes characters that are not valid in a CSS class selector,
+// including digits starting it (or following its leading hyphen)
+func cssEscape(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		leading := i == 0 || i == 1 && s[0] == '-'
+		if leading && r >= '0' && r <= '9' {
+			// escaped by code point, which a space ends
+			fmt.Fprintf(&b, "\\%x ", r)
+			continue
+		}
+		if r == '-' || r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r > 0x7f {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
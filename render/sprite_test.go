@@ -0,0 +1,95 @@
+package render_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codelif/xdgicons"
+	"github.com/codelif/xdgicons/fake"
+	"github.com/codelif/xdgicons/render"
+)
+
+// as saved by Inkscape, with the same gradient id in every icon
+const inkscapeSVG = `<?xml version="1.0"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"
+  xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape"
+  xmlns:sodipodi="http://sodipodi.sourceforge.net/DTD/sodipodi-0.dtd"
+  width="16" height="16" inkscape:version="1.2">
+  <sodipodi:namedview id="namedview" inkscape:zoom="8"/>
+  <defs><linearGradient id="grad"><stop offset="0" stop-color="COLOR"/></linearGradient></defs>
+  <style>.a { fill: url(#grad); }</style>
+  <rect id="shape" inkscape:label="Layer" width="16" height="16" fill="url(#grad)"/>
+  <use xlink:href="#shape"/>
+</svg>`
+
+func TestWriteSVGSymbols(t *testing.T) {
+	dir := t.TempDir()
+	finder := fake.New()
+	for _, name := range []string{"first", "second"} {
+		iconPath := filepath.Join(dir, name+".svg")
+		data := strings.ReplaceAll(inkscapeSVG, "COLOR", name)
+		if err := os.WriteFile(iconPath, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		finder.Add(xdgicons.Icon{Name: name, Path: iconPath, Size: 16})
+	}
+
+	var buf bytes.Buffer
+	if _, err := render.WriteSVGSymbols(&buf, finder, []string{"first", "second"}, 16, 1); err != nil {
+		t.Fatal(err)
+	}
+	sheet := buf.String()
+
+	// well-formed, with every prefix declared
+	decoder := xml.NewDecoder(strings.NewReader(sheet))
+	ids := make(map[string]bool)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("sheet isn't well-formed: %v\n%s", err, sheet)
+		}
+		if element, ok := token.(xml.StartElement); ok {
+			for _, attr := range element.Attr {
+				if attr.Name.Local != "id" {
+					continue
+				}
+				if ids[attr.Value] {
+					t.Errorf("id %q is used twice", attr.Value)
+				}
+				ids[attr.Value] = true
+			}
+		}
+	}
+
+	for _, want := range []string{
+		`id="first__grad"`, `fill="url(#first__grad)"`, `url(#first__grad)`,
+		`xlink:href="#second__shape"`, `id="second__grad"`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet lacks %s:\n%s", want, sheet)
+		}
+	}
+	if strings.Contains(sheet, "inkscape") || strings.Contains(sheet, "namedview") {
+		t.Errorf("sheet contains Inkscape markup:\n%s", sheet)
+	}
+}
+
+func TestWriteCSSEscapesLeadingDigits(t *testing.T) {
+	sheet := &render.SpriteSheet{Entries: []render.SpriteEntry{{Name: "0ad", Width: 16, Height: 16}}}
+
+	var buf bytes.Buffer
+	if err := sheet.WriteCSS(&buf, "sheet.png", ""); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `.\30 ad {`) {
+		t.Errorf("leading digit isn't escaped:\n%s", buf.String())
+	}
+}
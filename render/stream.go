@@ -0,0 +1,86 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/codelif/xdgicons"
+)
+
+// Format icons are written in by [RenderIcon] and [EncodeImage]
+type Format int
+
+const (
+	// Rendered at the requested size and encoded as PNG
+	PNG Format = iota
+
+	// The icon file as is, in whatever format and size it has,
+	// e.g. for clients that render SVGs themselves
+	Original
+
+	// Windows icon, holding the image at several sizes
+	ICO
+
+	// Uncompressed Windows bitmap
+	BMP
+
+	// Lossless WebP
+	WebP
+
+	// Quite OK Image format
+	QOI
+)
+
+func (f Format) String() string {
+	switch f {
+	case PNG:
+		return "PNG"
+	case Original:
+		return "Original"
+	case ICO:
+		return "ICO"
+	case BMP:
+		return "BMP"
+	case WebP:
+		return "WebP"
+	case QOI:
+		return "QOI"
+	}
+	return "Format(?)"
+}
+
+// RenderIcon resolves iconName with finder and writes it to w in the
+// format set by opts, rendered to a (size*scale)×(size*scale) image
+// unless it's Original, so HTTP handlers and IPC servers can serve
+// icons without intermediate files.
+//
+// Errors of finder are returned as is, e.g. to tell missing
+// icons apart with [xdgicons.ErrIconNotFound].
+func RenderIcon(w io.Writer, finder xdgicons.Finder, iconName string, size, scale int, opts RenderOptions) error {
+	icon, err := finder.FindIcon(iconName, size, scale)
+	if err != nil {
+		return err
+	}
+
+	if opts.Format == Original {
+		f, err := os.Open(icon.Path)
+		if err != nil {
+			return fmt.Errorf("error reading file: %v", err)
+		}
+		defer f.Close()
+		if _, err := io.Copy(w, f); err != nil {
+			return fmt.Errorf("error writing icon: %v", err)
+		}
+		return nil
+	}
+
+	img, err := LoadWithOptions(icon.Path, size*scale, opts)
+	if err != nil {
+		return fmt.Errorf("error loading %q: %v", icon.Path, err)
+	}
+	if err := EncodeImage(w, img, opts.Format); err != nil {
+		return fmt.Errorf("error writing icon: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Colors symbolic icons are drawn with, see [RenderOptions.Symbolic].
+// Alpha is ignored, unset colors default to the ones GTK falls
+// back to without a theme.
+type SymbolicColors struct {
+	// Color of plain shapes and the foreground classes
+	Foreground color.Color
+
+	// Colors of the success, warning and error classes
+	Success color.Color
+	Warning color.Color
+	Error   color.Color
+}
+
+// the stylesheet of symbolic icons, as applied by GTK: rect, circle and
+// path elements get the foreground color, the classes override it
+var (
+	symbolicShapes      = []string{"rect", "circle", "path"}
+	symbolicFillRoles   = []string{"transparent-fill", "success", "success-fill", "error", "error-fill", "warning", "warning-fill", "foreground-fill"}
+	symbolicStrokeRoles = []string{"success-stroke", "error-stroke", "warning-stroke", "foreground-stroke"}
+)
+
+// reports whether iconPath is a symbolic svg, like GTK
+// only recolors icons whose name ends in -symbolic
+func isSymbolicSVG(iconPath string) bool {
+	return strings.HasSuffix(strings.ToLower(path.Base(iconPath)), "-symbolic.svg")
+}
+
+// returns the color of a symbolic class, "none" for transparent-fill
+func (c SymbolicColors) role(class string) string {
+	var value color.Color
+	var fallback string
+	switch strings.TrimSuffix(strings.TrimSuffix(class, "-fill"), "-stroke") {
+	case "transparent":
+		return "none"
+	case "success":
+		value, fallback = c.Success, "#4e9a06"
+	case "warning":
+		value, fallback = c.Warning, "#f57900"
+	case "error":
+		value, fallback = c.Error, "#cc0000"
+	default:
+		value, fallback = c.Foreground, "#bebebe"
+	}
+	if value == nil {
+		return fallback
+	}
+	rgba := color.NRGBAModel.Convert(value).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B)
+}
+
+// properties whose currentColor is the foreground color
+var symbolicColorProperties = []string{"fill", "stroke", "stop-color", "flood-color", "lighting-color"}
+
+// Applies the symbolic stylesheet to the svg in data. The matched
+// elements get the colors in their style attribute, replacing their
+// fill or stroke attributes and declarations as well as their classes,
+// since the stylesheet overrides those and the renderer only supports
+// simple class selectors. currentColor is the foreground color.
+// Everything else, e.g. opacities, is kept as is.
+func recolorSymbolic(data []byte, colors SymbolicColors) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+
+	var out bytes.Buffer
+	var copied int64
+	for {
+		start := decoder.InputOffset()
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		var classes []string
+		for _, attr := range element.Attr {
+			if attr.Name.Space == "" && attr.Name.Local == "class" {
+				classes = strings.Fields(attr.Value)
+			}
+		}
+
+		var fill, stroke string
+		if i := slices.IndexFunc(symbolicFillRoles, func(role string) bool {
+			return slices.Contains(classes, role)
+		}); i >= 0 {
+			fill = colors.role(symbolicFillRoles[i])
+		} else if element.Name.Space == "" && slices.Contains(symbolicShapes, element.Name.Local) {
+			fill = colors.role("foreground")
+		}
+		if i := slices.IndexFunc(symbolicStrokeRoles, func(role string) bool {
+			return slices.Contains(classes, role)
+		}); i >= 0 {
+			stroke = colors.role(symbolicStrokeRoles[i])
+		}
+
+		attrs, changed := recolorAttrs(element.Attr, fill, stroke, colors.role("foreground"))
+		if !changed {
+			continue
+		}
+
+		end := decoder.InputOffset()
+		selfClosing := bytes.HasSuffix(bytes.TrimRight(data[:end], " \t\r\n"), []byte("/>"))
+
+		out.Write(data[copied:start])
+		out.WriteByte('<')
+		out.WriteString(rawName(element.Name))
+		for _, attr := range attrs {
+			fmt.Fprintf(&out, " %s=\"%s\"", rawName(attr.Name), xmlEscape(attr.Value))
+		}
+		if selfClosing {
+			out.WriteString("/>")
+		} else {
+			out.WriteByte('>')
+		}
+		copied = end
+	}
+	out.Write(data[copied:])
+	return out.Bytes(), nil
+}
+
+// Returns attrs with fill and stroke (unless empty) set in the style
+// attribute, replacing the attributes and declarations setting them,
+// and currentColor replaced by foreground. Reports whether anything
+// changed.
+func recolorAttrs(attrs []xml.Attr, fill, stroke, foreground string) ([]xml.Attr, bool) {
+	changed := fill != "" || stroke != ""
+	var recolored []xml.Attr
+	var style []styleDeclaration
+	for _, attr := range attrs {
+		if attr.Name.Space != "" {
+			recolored = append(recolored, attr)
+			continue
+		}
+		name := strings.ToLower(attr.Name.Local)
+		switch {
+		case name == "class" && changed,
+			name == "fill" && fill != "",
+			name == "stroke" && stroke != "":
+			continue
+		case name == "style":
+			style = parseStyle(attr.Value)
+			continue
+		case slices.Contains(symbolicColorProperties, name) && isCurrentColor(attr.Value):
+			attr.Value = foreground
+			changed = true
+		}
+		recolored = append(recolored, attr)
+	}
+
+	var declarations []styleDeclaration
+	for _, declaration := range style {
+		switch {
+		case declaration.property == "fill" && fill != "",
+			declaration.property == "stroke" && stroke != "":
+			changed = true
+			continue
+		case slices.Contains(symbolicColorProperties, declaration.property) && isCurrentColor(declaration.value):
+			declaration.value = foreground
+			changed = true
+		}
+		declarations = append(declarations, declaration)
+	}
+	if fill != "" {
+		declarations = append(declarations, styleDeclaration{"fill", fill})
+	}
+	if stroke != "" {
+		declarations = append(declarations, styleDeclaration{"stroke", stroke})
+	}
+
+	if len(declarations) > 0 {
+		var value strings.Builder
+		for i, declaration := range declarations {
+			if i > 0 {
+				value.WriteByte(';')
+			}
+			value.WriteString(declaration.property + ":" + declaration.value)
+		}
+		recolored = append(recolored, xml.Attr{Name: xml.Name{Local: "style"}, Value: value.String()})
+	}
+	return recolored, changed
+}
+
+// a property: value pair of a style attribute
+type styleDeclaration struct {
+	property string
+	value    string
+}
+
+// Splits a style attribute into its declarations, lowercasing
+// the properties and trimming spaces. Declarations without a
+// colon are dropped, like browsers do.
+func parseStyle(style string) []styleDeclaration {
+	var declarations []styleDeclaration
+	for _, declaration := range strings.Split(style, ";") {
+		property, value, ok := strings.Cut(declaration, ":")
+		if !ok {
+			continue
+		}
+		declarations = append(declarations, styleDeclaration{
+			property: strings.ToLower(strings.TrimSpace(property)),
+			value:    strings.TrimSpace(value),
+		})
+	}
+	return declarations
+}
+
+// reports whether value is currentColor, which CSS doesn't
+// care about the case of, possibly marked !important
+func isCurrentColor(value string) bool {
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important"))
+	return strings.EqualFold(value, "currentColor")
+}
+
+// the name as written in the file, with its prefix
+func rawName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
@@ -0,0 +1,290 @@
+package render
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+	"slices"
+)
+
+// order the lengths of the code length code are stored in
+var webpCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// Encodes img as a lossless WebP. Pixels are stored as literals, without
+// transforms or backward references, each channel with a Huffman code
+// of its own, so images with few colors or no transparency compress well.
+func encodeWebP(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > 1<<14 || height > 1<<14 {
+		return fmt.Errorf("invalid size %dx%d for webp", width, height)
+	}
+
+	pixels := make([]color.NRGBA, 0, width*height)
+	// green, red, blue and alpha, with green having room for the
+	// unused length prefixes
+	histograms := [4][]int{make([]int, 256+24), make([]int, 256), make([]int, 256), make([]int, 256)}
+	alphaUsed := false
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			px := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			pixels = append(pixels, px)
+			histograms[0][px.G]++
+			histograms[1][px.R]++
+			histograms[2][px.B]++
+			histograms[3][px.A]++
+			alphaUsed = alphaUsed || px.A != 0xff
+		}
+	}
+
+	var bw webpBitWriter
+	bw.write(0x2f, 8)
+	bw.write(uint64(width-1), 14)
+	bw.write(uint64(height-1), 14)
+	if alphaUsed {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	// version 0, no transforms, no color cache, no meta prefix codes
+	bw.write(0, 3+1+1+1)
+
+	var codes [4][]webpCode
+	for i, histogram := range histograms {
+		codes[i] = bw.writePrefixCode(huffmanLengths(histogram, 15))
+	}
+	// distances are never used
+	bw.writePrefixCode(make([]uint8, 40))
+
+	for _, px := range pixels {
+		for i, value := range [4]uint8{px.G, px.R, px.B, px.A} {
+			code := codes[i][value]
+			bw.write(uint64(code.bits), code.length)
+		}
+	}
+	data := bw.flush()
+
+	out := make([]byte, 0, 20+len(data)+1)
+	out = append(out, "RIFF"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(4+8+len(data)+len(data)%2))
+	out = append(out, "WEBPVP8L"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(data)))
+	out = append(out, data...)
+	if len(data)%2 == 1 {
+		out = append(out, 0)
+	}
+	_, err := w.Write(out)
+	return err
+}
+
+// a Huffman code with its bits reversed, in the order they're written
+type webpCode struct {
+	bits   uint32
+	length uint
+}
+
+// writes bits LSB first, as VP8L reads them
+type webpBitWriter struct {
+	buf  []byte
+	acc  uint64
+	nacc uint
+}
+
+func (bw *webpBitWriter) write(value uint64, n uint) {
+	bw.acc |= value << bw.nacc
+	bw.nacc += n
+	for bw.nacc >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nacc -= 8
+	}
+}
+
+func (bw *webpBitWriter) flush() []byte {
+	if bw.nacc > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.nacc = 0, 0
+	}
+	return bw.buf
+}
+
+// Writes the prefix code with the code lengths, and returns the codes
+// of its symbols. Codes of up to two symbols below 256 use the simple
+// form, where a single symbol takes no bits at all.
+func (bw *webpBitWriter) writePrefixCode(lengths []uint8) []webpCode {
+	var symbols []int
+	for symbol, length := range lengths {
+		if length > 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	if len(symbols) <= 2 && (len(symbols) == 0 || symbols[len(symbols)-1] < 256) {
+		if len(symbols) == 0 {
+			symbols = []int{0}
+		}
+		bw.write(1, 1)
+		bw.write(uint64(len(symbols)-1), 1)
+		if symbols[0] < 2 {
+			bw.write(0, 1)
+			bw.write(uint64(symbols[0]), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint64(symbols[0]), 8)
+		}
+		if len(symbols) == 2 {
+			bw.write(uint64(symbols[1]), 8)
+		}
+
+		codes := make([]webpCode, len(lengths))
+		if len(symbols) == 2 {
+			codes[symbols[1]] = webpCode{bits: 1, length: 1}
+			codes[symbols[0]] = webpCode{bits: 0, length: 1}
+		}
+		return codes
+	}
+
+	// the lengths are stored literally, with a code of their own
+	var histogram [19]int
+	for _, length := range lengths {
+		histogram[length]++
+	}
+	lengthLengths := huffmanLengths(histogram[:], 7)
+	lengthCodes := canonicalCodes(lengthLengths)
+
+	count := 4
+	for i, symbol := range webpCodeLengthOrder {
+		if lengthLengths[symbol] > 0 {
+			count = max(count, i+1)
+		}
+	}
+	bw.write(0, 1)
+	bw.write(uint64(count-4), 4)
+	for _, symbol := range webpCodeLengthOrder[:count] {
+		bw.write(uint64(lengthLengths[symbol]), 3)
+	}
+	// lengths of all symbols follow
+	bw.write(0, 1)
+	for _, length := range lengths {
+		code := lengthCodes[length]
+		bw.write(uint64(code.bits), code.length)
+	}
+
+	return canonicalCodes(lengths)
+}
+
+// Assigns canonical Huffman codes to lengths, like deflate does. A single
+// symbol gets an empty code.
+func canonicalCodes(lengths []uint8) []webpCode {
+	codes := make([]webpCode, len(lengths))
+
+	var count [16]uint32
+	used := 0
+	for _, length := range lengths {
+		if length > 0 {
+			count[length]++
+			used++
+		}
+	}
+	if used < 2 {
+		return codes
+	}
+
+	var next [16]uint32
+	code := uint32(0)
+	for length := 1; length < 16; length++ {
+		code = (code + count[length-1]) << 1
+		next[length] = code
+	}
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		codes[symbol] = webpCode{
+			bits:   bits.Reverse32(next[length]) >> (32 - uint(length)),
+			length: uint(length),
+		}
+		next[length]++
+	}
+	return codes
+}
+
+// Returns the lengths of a Huffman code for the symbol frequencies,
+// limited to maxLength bits by flattening the frequencies until the
+// code fits. A single used symbol gets length 1.
+func huffmanLengths(frequencies []int, maxLength int) []uint8 {
+	lengths := make([]uint8, len(frequencies))
+	frequencies = slices.Clone(frequencies)
+	for {
+		h := &huffmanHeap{}
+		for symbol, frequency := range frequencies {
+			if frequency > 0 {
+				*h = append(*h, &huffmanNode{frequency: frequency, symbol: symbol})
+			}
+		}
+		switch h.Len() {
+		case 0:
+			return lengths
+		case 1:
+			lengths[(*h)[0].symbol] = 1
+			return lengths
+		}
+
+		heap.Init(h)
+		for h.Len() > 1 {
+			a := heap.Pop(h).(*huffmanNode)
+			b := heap.Pop(h).(*huffmanNode)
+			heap.Push(h, &huffmanNode{frequency: a.frequency + b.frequency, symbol: -1, children: [2]*huffmanNode{a, b}})
+		}
+
+		fits := true
+		var walk func(node *huffmanNode, depth int)
+		walk = func(node *huffmanNode, depth int) {
+			if node.symbol >= 0 {
+				lengths[node.symbol] = uint8(depth)
+				fits = fits && depth <= maxLength
+				return
+			}
+			walk(node.children[0], depth+1)
+			walk(node.children[1], depth+1)
+		}
+		walk(heap.Pop(h).(*huffmanNode), 0)
+		if fits {
+			return lengths
+		}
+
+		for symbol, frequency := range frequencies {
+			if frequency > 0 {
+				frequencies[symbol] = (frequency + 1) / 2
+			}
+		}
+	}
+}
+
+type huffmanNode struct {
+	frequency int
+	symbol    int
+	children  [2]*huffmanNode
+}
+
+type huffmanHeap []*huffmanNode
+
+func (h huffmanHeap) Len() int { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool {
+	if h[i].frequency != h[j].frequency {
+		return h[i].frequency < h[j].frequency
+	}
+	return h[i].symbol > h[j].symbol
+}
+func (h huffmanHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x any)   { *h = append(*h, x.(*huffmanNode)) }
+func (h *huffmanHeap) Pop() any {
+	old := *h
+	node := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return node
+}
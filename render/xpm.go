@@ -0,0 +1,191 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// Decodes an XPM3 image, a C array of strings: the values
+// "width height ncolors cpp", one color per line keyed by cpp
+// characters, then height rows of pixels.
+//
+// Colors may be given for several visuals, color ("c") is preferred
+// over grayscale ("g", "g4") and monochrome ("m"). Symbolic names
+// ("s") are ignored, since there is no one to pass their values.
+func decodeXPM(data []byte) (image.Image, error) {
+	lines, err := xpmStrings(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("no values")
+	}
+
+	values := strings.Fields(lines[0])
+	if len(values) < 4 {
+		return nil, fmt.Errorf("invalid values %q", lines[0])
+	}
+	var width, height, colorCount, cpp int
+	for i, value := range []*int{&width, &height, &colorCount, &cpp} {
+		*value, err = strconv.Atoi(values[i])
+		if err != nil || *value < 0 {
+			return nil, fmt.Errorf("invalid values %q", lines[0])
+		}
+	}
+	// every pixel takes cpp characters of the file
+	if cpp == 0 || width == 0 || height == 0 || width > len(data)/cpp {
+		return nil, fmt.Errorf("invalid values %q", lines[0])
+	}
+	// checked one at a time first, so the sum can't overflow
+	if colorCount > len(lines) || height > len(lines) || len(lines) < 1+colorCount+height {
+		return nil, errors.New("file is truncated")
+	}
+
+	colors := make(map[string]color.NRGBA, colorCount)
+	for _, line := range lines[1 : 1+colorCount] {
+		if len(line) < cpp {
+			return nil, fmt.Errorf("invalid color %q", line)
+		}
+		c, err := xpmColor(line[cpp:])
+		if err != nil {
+			return nil, err
+		}
+		colors[line[:cpp]] = c
+	}
+
+	rows := lines[1+colorCount : 1+colorCount+height]
+	for _, row := range rows {
+		if len(row) != width*cpp {
+			return nil, fmt.Errorf("row has %d characters instead of %d", len(row), width*cpp)
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y, row := range rows {
+		for x := range width {
+			key := row[x*cpp : (x+1)*cpp]
+			c, ok := colors[key]
+			if !ok {
+				return nil, fmt.Errorf("undefined color %q", key)
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
+
+// the visuals colors can be given for, in order of preference
+var xpmVisuals = []string{"c", "g", "g4", "m"}
+
+// parses the part of a color line after the key, e.g. "c #ff0000 m black"
+func xpmColor(spec string) (color.NRGBA, error) {
+	values := make(map[string]string)
+	var visual string
+	for _, word := range strings.Fields(spec) {
+		if visual == "" || values[visual] != "" {
+			switch word {
+			case "c", "g", "g4", "m", "s":
+				visual = word
+				continue
+			}
+		}
+		if visual == "" {
+			return color.NRGBA{}, fmt.Errorf("invalid color %q", spec)
+		}
+		// names may contain spaces, e.g. "light grey"
+		if values[visual] != "" {
+			values[visual] += " "
+		}
+		values[visual] += word
+	}
+
+	for _, visual := range xpmVisuals {
+		if value := values[visual]; value != "" {
+			return parseXPMColor(value)
+		}
+	}
+	return color.NRGBA{}, fmt.Errorf("invalid color %q", spec)
+}
+
+// parses a hex color, "None" or an X11 color name
+func parseXPMColor(value string) (color.NRGBA, error) {
+	if strings.EqualFold(value, "none") {
+		return color.NRGBA{}, nil
+	}
+
+	if hex, ok := strings.CutPrefix(value, "#"); ok {
+		// 1 to 4 digits per channel
+		n := len(hex) / 3
+		if n == 0 || n > 4 || len(hex)%3 != 0 {
+			return color.NRGBA{}, fmt.Errorf("invalid color %q", value)
+		}
+		var channels [3]uint8
+		for i := range channels {
+			v, err := strconv.ParseUint(hex[i*n:(i+1)*n], 16, 16)
+			if err != nil {
+				return color.NRGBA{}, fmt.Errorf("invalid color %q", value)
+			}
+			if n == 1 {
+				channels[i] = uint8(v * 0x11)
+			} else {
+				channels[i] = uint8(v >> (4 * (n - 2)))
+			}
+		}
+		return color.NRGBA{channels[0], channels[1], channels[2], 0xff}, nil
+	}
+
+	name := strings.ToLower(strings.ReplaceAll(value, " ", ""))
+	if c, ok := colornames.Map[name]; ok {
+		return color.NRGBA{c.R, c.G, c.B, 0xff}, nil
+	}
+
+	// X11 also has the grays from gray0 to gray100
+	for _, prefix := range []string{"gray", "grey"} {
+		if level, ok := strings.CutPrefix(name, prefix); ok {
+			percent, err := strconv.Atoi(level)
+			if err == nil && percent >= 0 && percent <= 100 {
+				v := uint8(math.Round(float64(percent) * 255 / 100))
+				return color.NRGBA{v, v, v, 0xff}, nil
+			}
+		}
+	}
+
+	return color.NRGBA{}, fmt.Errorf("unknown color %q", value)
+}
+
+// extracts the string literals of the C source in data, skipping comments
+func xpmStrings(data []byte) ([]string, error) {
+	var lines []string
+	for i := 0; i < len(data); i++ {
+		switch {
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				return nil, errors.New("unterminated comment")
+			}
+			i += end + 3
+		case data[i] == '"':
+			var s strings.Builder
+			i++
+			for ; i < len(data) && data[i] != '"'; i++ {
+				if data[i] == '\\' && i+1 < len(data) {
+					i++
+				}
+				s.WriteByte(data[i])
+			}
+			if i == len(data) {
+				return nil, errors.New("unterminated string")
+			}
+			lines = append(lines, s.String())
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,40 @@
+package render
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDecodeXPM(t *testing.T) {
+	data := []byte(`/* XPM */
+static char *icon[] = {
+"2 2 2 1",
+"  c None",
+". c #ff0000",
+". ",
+" .",
+};`)
+	img, err := decodeXPM(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds().Dx(); got != 2 {
+		t.Errorf("width = %d, want 2", got)
+	}
+	if _, _, _, a := img.At(1, 0).RGBA(); a != 0 {
+		t.Errorf("alpha of None = %d, want 0", a)
+	}
+}
+
+// Headers whose counts overflow the size check used to panic
+func TestDecodeXPMHugeCounts(t *testing.T) {
+	for _, header := range []string{
+		"1 1 9223372036854775807 1",
+		"1 9223372036854775807 1 1",
+	} {
+		data := fmt.Appendf(nil, "static char *icon[] = {\n%q,\n\". c #ff0000\",\n\".\",\n};", header)
+		if _, err := decodeXPM(data); err == nil {
+			t.Errorf("decoding header %q succeeded, want an error", header)
+		}
+	}
+}
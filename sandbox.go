@@ -0,0 +1,44 @@
+package xdgicons
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+func resolveRoots(roots []string) []string {
+	resolved := make([]string, 0, len(roots))
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = real
+		}
+		resolved = append(resolved, abs)
+	}
+	return resolved
+}
+
+// reports whether filePath, after resolving all symlinks,
+// lies inside one of the allowed roots.
+//
+// Always true if no allowlist was configured.
+func (il *IconLookup) pathAllowed(filePath string) bool {
+	if il.allowedRoots == nil {
+		return true
+	}
+
+	real, err := filepath.EvalSymlinks(filePath)
+	if err != nil {
+		return false
+	}
+
+	for _, root := range il.allowedRoots {
+		rel, err := filepath.Rel(root, real)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package xdgicons_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/codelif/xdgicons"
+	"github.com/codelif/xdgicons/testutil"
+)
+
+func TestAllowedRoots(t *testing.T) {
+	baseDir := testutil.SetupEnv(t, testutil.Hicolor("app.png"))
+
+	for _, tt := range []struct {
+		roots []string
+		found bool
+	}{
+		{[]string{"/"}, true},
+		{[]string{filepath.Dir(baseDir) + "/"}, true},
+		{[]string{baseDir}, true},
+		{[]string{baseDir + "-other"}, false},
+		{[]string{filepath.Join(baseDir, "hicolor", "16x16")}, false},
+	} {
+		il := xdgicons.NewIconLookupWithConfig(xdgicons.LookupConfig{AllowedRoots: tt.roots})
+		_, err := il.FindIcon("app", 48, 1)
+		if found := err == nil; found != tt.found {
+			t.Errorf("with AllowedRoots %q, found = %v (%v), want %v", tt.roots, found, err, tt.found)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package xdgicons
+
+import (
+	"context"
+	"path"
+	"slices"
+)
+
+// Adds dir to the searched base directories, after all others but
+// /usr/share/pixmaps, e.g. for the IconThemePath of a StatusNotifierItem.
+//
+// Only dir is indexed, the cache of the other directories is kept.
+func (il *IconLookup) AddSearchPath(dir string) {
+	dir = path.Clean(dir)
+
+	il.mu.Lock()
+	if slices.Contains(il.baseDirs, dir) {
+		il.mu.Unlock()
+		return
+	}
+
+	if il.customBaseDirs != nil {
+		il.customBaseDirs = append(slices.Clone(il.customBaseDirs), dir)
+	} else {
+		il.additionalBaseDirs = append(slices.Clone(il.additionalBaseDirs), dir)
+	}
+
+	infos := slices.Clone(il.baseDirInfos)
+	pixmaps := slices.IndexFunc(infos, func(info BaseDirInfo) bool {
+		return info.Source == SourcePixmaps
+	})
+	if pixmaps < 0 {
+		pixmaps = len(infos)
+	}
+	infos = slices.Insert(infos, pixmaps, BaseDirInfo{
+		Path:   dir,
+		Source: SourceCustom,
+	})
+	il.setBaseDirs(numberCustomDirs(infos))
+	if !slices.Contains(il.baseDirs, dir) {
+		// a link to a directory that is searched already
+		il.mu.Unlock()
+		return
+	}
+
+	// themes that were missing so far may be in dir
+	il.missingThemes = make(map[string]bool)
+	dw := il.watcher
+	il.mu.Unlock()
+
+	_ = il.cacheBaseDirectory(context.Background(), dir)
+
+	if dw != nil {
+		il.watchBaseDir(dw, dir)
+	}
+}
+
+// Stops searching dir, if it was added with [IconLookup.AddSearchPath]
+// or configured in [LookupConfig.BaseDirs] or
+// [LookupConfig.AdditionalBaseDirs]. Base directories from the
+// environment are not affected.
+func (il *IconLookup) RemoveSearchPath(dir string) {
+	dir = path.Clean(dir)
+
+	il.mu.Lock()
+	defer il.mu.Unlock()
+
+	if !slices.Contains(il.customBaseDirs, dir) && !slices.Contains(il.additionalBaseDirs, dir) {
+		return
+	}
+
+	isDir := func(d string) bool { return d == dir }
+	if il.customBaseDirs != nil {
+		il.customBaseDirs = slices.DeleteFunc(slices.Clone(il.customBaseDirs), isDir)
+	}
+	il.additionalBaseDirs = slices.DeleteFunc(slices.Clone(il.additionalBaseDirs), isDir)
+
+	il.setBaseDirs(numberCustomDirs(slices.DeleteFunc(slices.Clone(il.baseDirInfos), func(info BaseDirInfo) bool {
+		return info.Source == SourceCustom && info.Path == dir
+	})))
+	delete(il.dirCache, dir)
+	delete(il.evictedDirs, dir)
+	delete(il.invalidatedDirs, dir)
+	delete(il.missingDirs, dir)
+	il.clearThemeInfoCache()
+}
+
+// sets the Index of the custom directories in infos to their position
+// among the custom directories
+func numberCustomDirs(infos []BaseDirInfo) []BaseDirInfo {
+	index := 0
+	for i := range infos {
+		if infos[i].Source == SourceCustom {
+			infos[i].Index = index
+			index++
+		}
+	}
+	return infos
+}
@@ -0,0 +1,48 @@
+package xdgicons
+
+import (
+	"sync"
+	"weak"
+)
+
+// Base directory entries shared between lookups with
+// [LookupConfig.ShareCache], by base directory path.
+//
+// Entries are only referenced weakly, so they are freed
+// once no lookup uses them anymore.
+type sharedDirCache struct {
+	mu      sync.Mutex
+	entries map[string]weak.Pointer[baseDirIconCache]
+}
+
+var sharedCache = &sharedDirCache{
+	entries: make(map[string]weak.Pointer[baseDirIconCache]),
+}
+
+func (s *sharedDirCache) get(baseDir string) *baseDirIconCache {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cacheEntry := s.entries[baseDir].Value()
+	if cacheEntry == nil {
+		delete(s.entries, baseDir)
+	}
+	return cacheEntry
+}
+
+// publishes cacheEntry for baseDir, unless the shared entry
+// was scanned later, or is the same scan with more themes
+func (s *sharedDirCache) put(baseDir string, cacheEntry *baseDirIconCache) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing := s.entries[baseDir].Value(); existing != nil {
+		if existing.lastScan.After(cacheEntry.lastScan) {
+			return
+		}
+		if existing.lastScan.Equal(cacheEntry.lastScan) && !cacheEntry.covers(existing.themes) {
+			return
+		}
+	}
+	s.entries[baseDir] = weak.Make(cacheEntry)
+}
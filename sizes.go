@@ -0,0 +1,88 @@
+package xdgicons
+
+import (
+	"cmp"
+	"context"
+	"path"
+	"slices"
+)
+
+// A size an icon is available in
+type IconSizeInfo struct {
+	// Nominal (unscaled) size
+	Size int
+
+	// Scale of the icon
+	Scale int
+
+	// Type of the directory the icon is in:
+	// Fixed, Scalable or Threshold
+	Type string
+
+	// Minimum (unscaled) size the icon can be scaled to
+	MinSize int
+
+	// Maximum (unscaled) size the icon can be scaled to
+	MaxSize int
+}
+
+// Lists every size, scale and type iconName is available in across the
+// current theme and the themes it inherits from, ordered by scale and
+// size. Each combination is listed once.
+func (il *IconLookup) AvailableSizes(iconName string) ([]IconSizeInfo, error) {
+	ctx := context.Background()
+	opts := il.lookupOptions(LookupOptions{})
+
+	var sizes []IconSizeInfo
+	for _, theme := range il.themeChain(il.Theme()) {
+		themeInfo, err := il.getThemeInfo(theme)
+		if err != nil {
+			continue
+		}
+		for _, subdir := range themeInfo.allDirectories() {
+			if !il.directoryInContext(themeInfo, subdir) {
+				continue
+			}
+			iconInfo := themeInfo.directoryMap[subdir]
+			sizeInfo := IconSizeInfo{
+				Size:    iconInfo.Size,
+				Scale:   iconInfo.Scale,
+				Type:    iconInfo.Type,
+				MinSize: iconInfo.MinSize,
+				MaxSize: iconInfo.MaxSize,
+			}
+			if slices.Contains(sizes, sizeInfo) {
+				continue
+			}
+			if il.themeDirHasIcon(ctx, theme, subdir, iconName, opts) {
+				sizes = append(sizes, sizeInfo)
+			}
+		}
+	}
+
+	if len(sizes) == 0 {
+		return nil, il.iconNotFound([]string{iconName}, opts)
+	}
+
+	slices.SortFunc(sizes, func(a, b IconSizeInfo) int {
+		return cmp.Or(
+			cmp.Compare(a.Scale, b.Scale),
+			cmp.Compare(a.Size, b.Size),
+			cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.MinSize, b.MinSize),
+			cmp.Compare(a.MaxSize, b.MaxSize),
+		)
+	})
+	return sizes, nil
+}
+
+// reports whether subdir of theme holds iconName in any base directory
+func (il *IconLookup) themeDirHasIcon(ctx context.Context, theme, subdir, iconName string, opts LookupOptions) bool {
+	dir := path.Join(theme, subdir)
+	for _, directory := range il.getBaseDirs() {
+		if _, ok := il.findFile(ctx, directory, dir, iconName, opts.Extensions); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package xdgicons
+
+import "time"
+
+// Statistics of the caches of an IconLookup
+type CacheStats struct {
+	// Every base directory in search order, whether cached or not
+	BaseDirs []BaseDirCacheStats
+
+	// Number of files in the index, not counting
+	// themes indexed through an icon-theme.cache
+	Files int
+
+	// Estimated bytes held by the file index
+	Bytes int
+
+	// Number of themes whose directories are indexed
+	Themes int
+
+	// Number of parsed index.theme files
+	ThemeInfos int
+
+	// Number of file checks answered from the index right away
+	Hits uint64
+
+	// Number of file checks that had to rescan the base
+	// directory first, because its entry was outdated or evicted
+	Misses uint64
+
+	// Number of base directories dropped from the cache
+	// because [LookupConfig.MaxCachedFiles] was exceeded
+	Evictions uint64
+}
+
+// Cache statistics of a single base directory
+type BaseDirCacheStats struct {
+	Path string
+
+	// Whether the directory is currently indexed
+	Cached bool
+
+	// Whether the directory was dropped because
+	// [LookupConfig.MaxCachedFiles] was exceeded
+	Evicted bool
+
+	// Whether the directory was dropped by [IconLookup.InvalidateAll]
+	// and wasn't needed by a lookup since
+	Invalidated bool
+
+	// Whether the directory is skipped because scanning or
+	// checking it exceeded [LookupConfig.ScanTimeout]
+	Degraded bool
+
+	// Number of files in the index
+	Files int
+
+	// Number of themes indexed through an icon-theme.cache
+	GTKCaches int
+
+	// Estimated bytes held by the file index
+	Bytes int
+
+	// When the directory was last scanned
+	LastScan time.Time
+
+	// When the directory was last checked for changes
+	LastCheck time.Time
+
+	// When a lookup last used the directory
+	LastUsed time.Time
+}
+
+// Reports the current size and usage of the caches
+func (il *IconLookup) CacheStats() CacheStats {
+	il.mu.RLock()
+	defer il.mu.RUnlock()
+
+	stats := CacheStats{
+		Themes:     len(il.loadedThemes),
+		ThemeInfos: len(il.themeInfoCache),
+		Hits:       il.cacheHits.Load(),
+		Misses:     il.cacheMisses.Load(),
+		Evictions:  il.evictions,
+	}
+
+	for _, baseDir := range il.baseDirs {
+		dirStats := BaseDirCacheStats{
+			Path:        baseDir,
+			Evicted:     il.evictedDirs[baseDir],
+			Invalidated: il.invalidatedDirs[baseDir],
+			Degraded:    il.degradedDirs[baseDir] > 0,
+		}
+		if cacheEntry := il.dirCache[baseDir]; cacheEntry != nil {
+			dirStats.Cached = true
+			dirStats.Files = cacheEntry.files
+			dirStats.GTKCaches = len(cacheEntry.gtkCaches)
+			for dir, icons := range cacheEntry.icons {
+				dirStats.Bytes += indexDirSize(dir, icons)
+			}
+			dirStats.LastScan = cacheEntry.lastScan
+			dirStats.LastCheck = time.Unix(0, cacheEntry.lastStat.Load())
+			dirStats.LastUsed = time.Unix(0, cacheEntry.lastUsed.Load())
+		}
+
+		stats.BaseDirs = append(stats.BaseDirs, dirStats)
+		stats.Files += dirStats.Files
+		stats.Bytes += dirStats.Bytes
+	}
+	return stats
+}
@@ -0,0 +1,36 @@
+package xdgicons
+
+import (
+	"slices"
+	"strings"
+)
+
+const symbolicSuffix = "-symbolic"
+
+// returns the names to search for iconName, in order of preference
+func (il *IconLookup) symbolicVariants(iconName string) []string {
+	isSymbolic := strings.HasSuffix(iconName, symbolicSuffix)
+
+	var names []string
+	switch {
+	case il.preferSymbolic && !isSymbolic:
+		names = []string{iconName + symbolicSuffix, iconName}
+	case il.preferFullColor && !il.preferSymbolic && isSymbolic:
+		names = []string{strings.TrimSuffix(iconName, symbolicSuffix), iconName}
+	default:
+		names = []string{iconName}
+	}
+
+	var fallback string
+	switch {
+	case il.symbolicFallback && isSymbolic:
+		fallback = strings.TrimSuffix(iconName, symbolicSuffix)
+	case il.fullColorFallback && !isSymbolic:
+		fallback = iconName + symbolicSuffix
+	}
+	if fallback != "" && !slices.Contains(names, fallback) {
+		names = append(names, fallback)
+	}
+
+	return names
+}
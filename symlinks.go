@@ -0,0 +1,115 @@
+package xdgicons
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+)
+
+// Walks the directory tree at root like filepath.WalkDir, but also
+// descends into symlinked directories, which themes on NixOS or
+// linked into ~/.icons commonly are. visitDir is called with the
+// mtime of every directory, following links, and visitFile with
+// every file. Links to a directory the walk is already inside of
+// are skipped, so link loops can't recurse forever, and so are
+// links whose target allowed rejects, as is root itself.
+//
+// Unreadable directories are skipped.
+func walkFollowingLinks(ctx context.Context, root string, allowed func(string) bool, visitDir func(dirPath string, info fs.FileInfo), visitFile func(filePath string)) error {
+	real, err := filepath.EvalSymlinks(root)
+	if err != nil || !allowed(real) {
+		return nil
+	}
+	return walkDir(ctx, root, real, nil, allowed, visitDir, visitFile)
+}
+
+// walks dirPath, whose resolved path is real, below the
+// directories with the resolved paths in ancestors
+func walkDir(ctx context.Context, dirPath, real string, ancestors []string, allowed func(string) bool, visitDir func(string, fs.FileInfo), visitFile func(string)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if slices.Contains(ancestors, real) {
+		return nil
+	}
+	info, err := os.Stat(dirPath)
+	if err != nil {
+		return nil
+	}
+	visitDir(dirPath, info)
+
+	entries, _ := os.ReadDir(dirPath)
+	ancestors = append(ancestors, real)
+	for _, entry := range entries {
+		subPath := path.Join(dirPath, entry.Name())
+		subReal := path.Join(real, entry.Name())
+
+		isDir := entry.IsDir()
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if target, err := filepath.EvalSymlinks(subPath); err == nil {
+				if !allowed(target) {
+					continue
+				}
+				stat, err := os.Stat(target)
+				isDir = err == nil && stat.IsDir()
+				subReal = target
+			}
+		}
+
+		if !isDir {
+			visitFile(subPath)
+			continue
+		}
+		// clipped, so siblings appending to it don't share an array
+		if err := walkDir(ctx, subPath, subReal, slices.Clip(ancestors), allowed, visitDir, visitFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reports whether entry, found in dir, is a directory
+// or a symlink to one
+func isDirEntry(dir string, entry fs.DirEntry) bool {
+	if entry.IsDir() {
+		return true
+	}
+	if entry.Type()&fs.ModeSymlink == 0 {
+		return false
+	}
+	stat, err := os.Stat(path.Join(dir, entry.Name()))
+	return err == nil && stat.IsDir()
+}
+
+// Returns what dirPath resolves to, if it or one of its parents
+// is a symlink. Replacing such a link (e.g. switching a NixOS
+// generation) changes what the directory contains without
+// changing any mtime below it.
+func resolveLink(dirPath string) (string, bool) {
+	real, err := filepath.EvalSymlinks(dirPath)
+	if err != nil || real == dirPath {
+		return "", false
+	}
+	return real, true
+}
+
+// Drops base directories that resolve to the same directory as an
+// earlier one, e.g. ~/.icons linking to ~/.local/share/icons, so
+// it is indexed and searched once.
+func dedupeBaseDirs(infos []BaseDirInfo) []BaseDirInfo {
+	seen := make(map[string]bool, len(infos))
+	return slices.DeleteFunc(infos, func(info BaseDirInfo) bool {
+		real, err := filepath.EvalSymlinks(info.Path)
+		if err != nil {
+			real = info.Path
+		}
+		if seen[real] {
+			return true
+		}
+		seen[real] = true
+		return false
+	})
+}
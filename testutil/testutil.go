@@ -0,0 +1,274 @@
+// Builds temporary icon theme trees, so code using xdgicons can be
+// tested hermetically instead of against the themes installed on the host
+package testutil
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// An icon theme to write to disk
+type Theme struct {
+	// Directory name of the theme
+	Name string
+
+	// Value of the Name key, defaults to Name
+	DisplayName string
+
+	// Value of the Inherits key, omitted if empty
+	Inherits []string
+
+	// Icon directories of the theme
+	Dirs []Dir
+
+	// If set, written as index.theme instead of generating it from
+	// the fields above, to produce malformed themes
+	RawIndex string
+
+	// Don't write an index.theme at all
+	NoIndex bool
+}
+
+// An icon directory of a theme
+type Dir struct {
+	// Path relative to the theme directory, e.g. "48x48/apps"
+	Path string
+
+	// Nominal size, written as the Size key
+	Size int
+
+	// Written as the respective keys if non-zero/non-empty
+	Scale     int
+	MinSize   int
+	MaxSize   int
+	Threshold int
+	Type      string
+	Context   string
+
+	// List the directory in ScaledDirectories instead of Directories
+	Scaled bool
+
+	// Create the directory and its icons, but don't list it in index.theme
+	Unlisted bool
+
+	// List the directory, but don't write its section
+	NoSection bool
+
+	// Icon files to create, with extension, e.g. "firefox.png".
+	// png files are valid images of Size*Scale pixels, svg files
+	// are valid documents and other files are empty.
+	Icons []string
+}
+
+// Returns a hicolor theme with the usual application directories,
+// containing the given icon files in every one of them
+func Hicolor(icons ...string) Theme {
+	theme := Theme{Name: "hicolor", DisplayName: "Hicolor"}
+	for _, size := range []int{16, 22, 24, 32, 48, 64, 128, 256} {
+		theme.Dirs = append(theme.Dirs, Dir{
+			Path:    fmt.Sprintf("%dx%d/apps", size, size),
+			Size:    size,
+			Type:    "Threshold",
+			Context: "Applications",
+			Icons:   filterExt(icons, ".png"),
+		})
+	}
+	theme.Dirs = append(theme.Dirs, Dir{
+		Path:    "scalable/apps",
+		Size:    128,
+		MinSize: 8,
+		MaxSize: 512,
+		Type:    "Scalable",
+		Context: "Applications",
+		Icons:   filterExt(icons, ".svg"),
+	})
+	return theme
+}
+
+// Writes theme to baseDir/theme.Name
+func WriteTheme(baseDir string, theme Theme) error {
+	themeDir := filepath.Join(baseDir, theme.Name)
+	if err := os.MkdirAll(themeDir, 0o755); err != nil {
+		return err
+	}
+
+	for _, dir := range theme.Dirs {
+		dirPath := filepath.Join(themeDir, dir.Path)
+		if err := os.MkdirAll(dirPath, 0o755); err != nil {
+			return err
+		}
+
+		pixels := dir.Size * max(1, dir.Scale)
+		for _, icon := range dir.Icons {
+			data, err := iconData(icon, pixels)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(dirPath, icon), data, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+
+	if theme.NoIndex {
+		return nil
+	}
+
+	index := theme.RawIndex
+	if index == "" {
+		index = theme.Index()
+	}
+	return os.WriteFile(filepath.Join(themeDir, "index.theme"), []byte(index), 0o644)
+}
+
+// Returns the generated index.theme contents of theme
+func (theme Theme) Index() string {
+	var b strings.Builder
+
+	displayName := theme.DisplayName
+	if displayName == "" {
+		displayName = theme.Name
+	}
+
+	var directories, scaledDirectories []string
+	for _, dir := range theme.Dirs {
+		switch {
+		case dir.Unlisted:
+		case dir.Scaled:
+			scaledDirectories = append(scaledDirectories, dir.Path)
+		default:
+			directories = append(directories, dir.Path)
+		}
+	}
+
+	fmt.Fprintf(&b, "[Icon Theme]\nName=%s\n", displayName)
+	if len(theme.Inherits) > 0 {
+		fmt.Fprintf(&b, "Inherits=%s\n", strings.Join(theme.Inherits, ","))
+	}
+	fmt.Fprintf(&b, "Directories=%s\n", strings.Join(directories, ","))
+	if len(scaledDirectories) > 0 {
+		fmt.Fprintf(&b, "ScaledDirectories=%s\n", strings.Join(scaledDirectories, ","))
+	}
+
+	for _, dir := range theme.Dirs {
+		if dir.Unlisted || dir.NoSection {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\n[%s]\nSize=%d\n", dir.Path, dir.Size)
+		writeInt(&b, "Scale", dir.Scale)
+		writeInt(&b, "MinSize", dir.MinSize)
+		writeInt(&b, "MaxSize", dir.MaxSize)
+		writeInt(&b, "Threshold", dir.Threshold)
+		if dir.Type != "" {
+			fmt.Fprintf(&b, "Type=%s\n", dir.Type)
+		}
+		if dir.Context != "" {
+			fmt.Fprintf(&b, "Context=%s\n", dir.Context)
+		}
+	}
+
+	return b.String()
+}
+
+// Creates a temporary base directory containing themes,
+// removed when the test finishes
+func NewBaseDir(t testing.TB, themes ...Theme) string {
+	t.Helper()
+
+	baseDir := filepath.Join(t.TempDir(), "icons")
+	if err := os.MkdirAll(baseDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, theme := range themes {
+		if err := WriteTheme(baseDir, theme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return baseDir
+}
+
+// Points HOME and the XDG data and config directories at a temporary
+// tree containing themes, for the duration of the test, and clears the
+// variables naming the desktop, Qt's platform theme, the snap, the
+// display and the session bus, so neither implicit inherits nor the
+// settings of the host leak into lookups created afterwards.
+//
+// Base directories that don't come from the environment are still
+// searched: /usr/share/pixmaps always, and the flatpak and snap
+// directories if this runs in a flatpak or snapd is installed. The
+// default theme may still be read from the system's dconf database,
+// so tests relying on the theme should set it.
+//
+// Returns the base directory the themes were written to.
+func SetupEnv(t testing.TB, themes ...Theme) string {
+	t.Helper()
+
+	root := t.TempDir()
+	home := filepath.Join(root, "home")
+	share := filepath.Join(root, "share")
+	baseDir := filepath.Join(share, "icons")
+
+	for _, dir := range []string{home, baseDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, theme := range themes {
+		if err := WriteTheme(baseDir, theme); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, ".local", "share"))
+	t.Setenv("XDG_DATA_DIRS", share)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("XDG_CONFIG_DIRS", filepath.Join(root, "etc", "xdg"))
+	for _, key := range []string{"XDG_CURRENT_DESKTOP", "QT_QPA_PLATFORMTHEME", "SNAP", "DISPLAY"} {
+		t.Setenv(key, "")
+	}
+	// nothing listens there, so the settings portal isn't asked
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path="+filepath.Join(root, "bus"))
+	return baseDir
+}
+
+func writeInt(b *strings.Builder, key string, value int) {
+	if value != 0 {
+		fmt.Fprintf(b, "%s=%d\n", key, value)
+	}
+}
+
+func filterExt(names []string, ext string) []string {
+	var filtered []string
+	for _, name := range names {
+		if strings.HasSuffix(name, ext) {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered
+}
+
+func iconData(name string, pixels int) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png":
+		img := image.NewNRGBA(image.Rect(0, 0, max(1, pixels), max(1, pixels)))
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i], img.Pix[i+3] = 0xff, 0xff
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case ".svg":
+		return []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="16" height="16" viewBox="0 0 16 16"><rect x="1" y="1" width="14" height="14" fill="#bebebe"/></svg>`), nil
+	}
+	return nil, nil
+}
@@ -3,6 +3,7 @@ package xdgicons
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"os/exec"
 	"path"
 	"slices"
@@ -11,7 +12,39 @@ import (
 	"gopkg.in/ini.v1"
 )
 
+// Detects the icon theme of the desktop: from kdeglobals in Plasma
+// sessions, from xfconf in XFCE ones and from qt5ct or qt6ct if Qt is
+// themed by them, otherwise asking the settings portal first and dconf
+// and gsettings after. Outside of desktops that keep their settings in
+// GSettings, GTK's settings.ini and then qt5ct and qt6ct come first.
+// Defaults to hicolor.
 func DefaultTheme() (theme string) {
+	switch {
+	case inDesktop("KDE"):
+		return kdeIconTheme()
+	case inDesktop("XFCE"):
+		if theme := xfceIconTheme(); theme != "" {
+			return theme
+		}
+	case usesQtct():
+		if theme := qtctIconTheme(); theme != "" {
+			return theme
+		}
+	case !slices.ContainsFunc(gsettingsDesktops, inDesktop):
+		// e.g. window managers, where GSettings
+		// only holds the default theme
+		if theme := gtkSettingsIconTheme(); theme != "" {
+			return theme
+		}
+		if theme := qtctIconTheme(); theme != "" {
+			return theme
+		}
+	}
+
+	if theme := portalIconTheme(); theme != "" {
+		return theme
+	}
+
 	dconfPath := []string{
 		"org",
 		"gnome",
@@ -45,34 +78,173 @@ func DefaultTheme() (theme string) {
 	return "hicolor"
 }
 
+// desktops whose icon theme setting lives in GSettings
+var gsettingsDesktops = []string{"GNOME", "Unity", "X-Cinnamon", "MATE", "Budgie", "Pantheon"}
+
+// the own themes of desktops, see [LookupConfig.ImplicitInherits]
+var desktopThemes = [][2]string{
+	{"GNOME", "Adwaita"},
+	{"KDE", "breeze"},
+	{"Pantheon", "elementary"},
+}
+
+// lists the own themes of the desktops named by XDG_CURRENT_DESKTOP
+func desktopInherits() []string {
+	var themes []string
+	for _, name := range strings.Split(os.Getenv("XDG_CURRENT_DESKTOP"), ":") {
+		for _, desktop := range desktopThemes {
+			if strings.EqualFold(name, desktop[0]) && !slices.Contains(themes, desktop[1]) {
+				themes = append(themes, desktop[1])
+			}
+		}
+	}
+	return themes
+}
+
+// reports whether XDG_CURRENT_DESKTOP names desktop
+func inDesktop(desktop string) bool {
+	for _, name := range strings.Split(os.Getenv("XDG_CURRENT_DESKTOP"), ":") {
+		if strings.EqualFold(name, desktop) {
+			return true
+		}
+	}
+	return false
+}
+
+// lists the XDG config directories in order of precedence
+func listConfigDirs() (configDirs []string) {
+	// relative paths are invalid per the basedir spec
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if !path.IsAbs(configHome) {
+		configHome = ""
+		if homeDir := os.Getenv("HOME"); homeDir != "" {
+			configHome = path.Join(homeDir, ".config")
+		}
+	}
+	if configHome != "" {
+		configDirs = append(configDirs, configHome)
+	}
+
+	dirs := os.Getenv("XDG_CONFIG_DIRS")
+	if dirs == "" {
+		dirs = "/etc/xdg"
+	}
+	for _, dir := range strings.Split(dirs, ":") {
+		if path.IsAbs(dir) {
+			configDirs = append(configDirs, dir)
+		}
+	}
+	return configDirs
+}
+
 func cleanDconfOutput(raw string) string {
 	return strings.TrimPrefix(strings.TrimSuffix(strings.Trim(raw, "\n "), "'"), "'")
 }
 
 // returns current theme
 func (il *IconLookup) Theme() string {
+	il.mu.RLock()
+	defer il.mu.RUnlock()
 	return il.theme
 }
 
 // returns fallback theme
 func (il *IconLookup) FallbackTheme() string {
+	il.mu.RLock()
+	defer il.mu.RUnlock()
 	return il.fallbackTheme
 }
 
+// Switches to theme, e.g. when the application's settings change, and
+// reports it to watchers. The theme and the ones it inherits from are
+// indexed right away, and themes that were missing so far are looked
+// for again, since they may just have been installed. The theme counts
+// as configured from now on, so the system theme isn't followed anymore.
+//
+// Returns a [ThemeNotFoundError] and keeps the current theme if theme
+// isn't installed.
+func (il *IconLookup) SetTheme(theme string) error {
+	theme = il.themeVariant(theme)
+	if err := il.prepareTheme(theme); err != nil {
+		return err
+	}
+
+	il.stopFollowingTheme()
+	il.stopXSettings()
+
+	il.mu.Lock()
+	switched := theme != il.theme
+	il.theme = theme
+	il.explicitTheme = true
+	il.mu.Unlock()
+
+	if switched {
+		il.notify(ChangeEvent{Kind: ThemeChanged, Theme: theme})
+	}
+	return nil
+}
+
+// Sets the theme searched when an icon isn't found in the current one,
+// indexing it like [IconLookup.SetTheme] does. An empty theme disables
+// the fallback.
+//
+// Returns a [ThemeNotFoundError] and keeps the current fallback theme
+// if theme isn't installed.
+func (il *IconLookup) SetFallbackTheme(theme string) error {
+	if theme != "" {
+		if err := il.prepareTheme(theme); err != nil {
+			return err
+		}
+	}
+
+	il.mu.Lock()
+	il.fallbackTheme = theme
+	il.mu.Unlock()
+	return nil
+}
+
+// forgets missing themes and indexes theme along
+// with its parents, if it's installed
+func (il *IconLookup) prepareTheme(theme string) error {
+	il.mu.Lock()
+	il.missingThemes = make(map[string]bool)
+	il.mu.Unlock()
+
+	if _, err := il.readThemeInfo(theme); err != nil {
+		return err
+	}
+	il.themeChain(theme)
+	return nil
+}
+
+// Returns the parsed index.theme of the installed theme name, e.g.
+// to follow its inheritance chain. If the theme is installed in
+// several base directories, their index.theme files are merged,
+// the first one taking precedence. Unlike lookups, this doesn't index
+// the theme's icons. Returns a [ThemeNotFoundError] if it isn't installed.
+func (il *IconLookup) ThemeInfo(name string) (ThemeInfo, error) {
+	return il.readThemeInfo(name)
+}
+
 func (il *IconLookup) readThemeIndex(theme, indexPath string) (*ThemeInfo, error) {
-	index, err := ini.Load(indexPath)
+	data, err := os.ReadFile(indexPath)
 	if err != nil {
 		return nil, fmt.Errorf("error reading file: %v", err)
 	}
 
-	iconThemeSection, err := index.GetSection("Icon Theme")
+	index, err := ini.Load(data)
 	if err != nil {
-		return nil, fmt.Errorf("error reading required section: %v", err)
+		return nil, fmt.Errorf("error reading file: %v", err)
+	}
+
+	var warnings []string
+	for _, section := range duplicateSections(data) {
+		warnings = append(warnings, fmt.Sprintf("section [%s] appears more than once, merged", section))
 	}
 
-	nameKey, err := iconThemeSection.GetKey("Name")
+	iconThemeSection, err := index.GetSection("Icon Theme")
 	if err != nil {
-		return nil, fmt.Errorf("error reading required key: %v", err)
+		return nil, fmt.Errorf("error reading required section: %v", err)
 	}
 
 	directorys, err := iconThemeSection.GetKey("Directories")
@@ -81,82 +253,159 @@ func (il *IconLookup) readThemeIndex(theme, indexPath string) (*ThemeInfo, error
 	}
 
 	themeInfo := &ThemeInfo{
-		Name:         nameKey.String(),
-		Directories:  directorys.Strings(","),
+		ID:           theme,
+		Directories:  normalizeDirList(directorys, &warnings),
 		directoryMap: make(map[string]SubDirIconInfo),
 	}
 
+	if nameKey, err := iconThemeSection.GetKey("Name"); err == nil {
+		themeInfo.Name = nameKey.String()
+	} else {
+		themeInfo.Name = theme
+		warnings = append(warnings, "required key Name is missing, the directory name is used")
+	}
+
 	inheritsKey, err := iconThemeSection.GetKey("Inherits")
 	if err == nil {
 		themeInfo.Inherits = inheritsKey.Strings(",")
+		if slices.Contains(themeInfo.Inherits, "") {
+			warnings = append(warnings, "Inherits has empty entries")
+			themeInfo.Inherits = slices.DeleteFunc(themeInfo.Inherits, func(parent string) bool {
+				return parent == ""
+			})
+		}
 	}
 
 	if theme != "hicolor" && !slices.Contains(themeInfo.Inherits, "hicolor") {
 		themeInfo.Inherits = slices.Insert(themeInfo.Inherits, len(themeInfo.Inherits), "hicolor")
+		themeInfo.implicitInherits = append(themeInfo.implicitInherits, "hicolor")
+	}
+
+	// the spec allows adding default themes right before hicolor.
+	// Those only get the ones after them, so they can't form a cycle
+	implicitInherits := il.implicitInherits
+	if i := slices.Index(implicitInherits, theme); i >= 0 {
+		implicitInherits = implicitInherits[i+1:]
+	}
+	for _, implicit := range implicitInherits {
+		if theme == "hicolor" || slices.Contains(themeInfo.Inherits, implicit) {
+			continue
+		}
+		hicolor := slices.Index(themeInfo.Inherits, "hicolor")
+		themeInfo.Inherits = slices.Insert(themeInfo.Inherits, hicolor, implicit)
+		themeInfo.implicitInherits = append(themeInfo.implicitInherits, implicit)
+	}
+
+	locales := messageLocales()
+	themeInfo.DisplayName = localizedValue(iconThemeSection, "Name", locales)
+	themeInfo.Comment = localizedValue(iconThemeSection, "Comment", locales)
+
+	themeInfo.localized = &themeStrings{
+		name:             themeInfo.Name,
+		localizedComment: themeInfo.Comment,
+		translations:     make(map[string]string),
+	}
+	if commentKey, err := iconThemeSection.GetKey("Comment"); err == nil {
+		themeInfo.localized.comment = commentKey.String()
+	}
+	for _, key := range iconThemeSection.Keys() {
+		if strings.HasPrefix(key.Name(), "Name[") || strings.HasPrefix(key.Name(), "Comment[") {
+			themeInfo.localized.translations[key.Name()] = key.String()
+		}
+	}
+
+	if exampleKey, err := iconThemeSection.GetKey("Example"); err == nil {
+		themeInfo.Example = exampleKey.String()
+	}
+	if hiddenKey, err := iconThemeSection.GetKey("Hidden"); err == nil {
+		themeInfo.Hidden = hiddenKey.MustBool(false)
 	}
 
 	scaledDirectorys, err := iconThemeSection.GetKey("ScaledDirectories")
 	if err == nil {
-		themeInfo.ScaledDirectories = scaledDirectorys.Strings(",")
+		themeInfo.ScaledDirectories = normalizeDirList(scaledDirectorys, &warnings)
 	}
 
-	for _, dir := range append(themeInfo.Directories, themeInfo.ScaledDirectories...) {
-		dirSection, err := index.GetSection(dir)
+	var broken []string
+	for _, dir := range themeInfo.allDirectories() {
+		subDirIconInfo, err := readDirSection(index, dir, &warnings)
 		if err != nil {
-			return nil, fmt.Errorf("error reading required section: %v", err)
+			broken = append(broken, dir)
+			problem := fmt.Sprintf("[%s] %v", dir, err)
+			if !il.strictThemeIndex {
+				problem += ", directory skipped"
+			}
+			warnings = append(warnings, problem)
+			continue
 		}
+		themeInfo.directoryMap[dir] = subDirIconInfo
+	}
 
-		sizeKey, err := dirSection.GetKey("Size")
-		if err != nil {
-			return nil, fmt.Errorf("error reading required key: %v", err)
-		}
+	if il.strictThemeIndex && len(warnings) > 0 {
+		return nil, fmt.Errorf("invalid index.theme: %s", strings.Join(warnings, "; "))
+	}
 
-		size, err := sizeKey.Int()
-		if err != nil {
-			return nil, fmt.Errorf("error parsing value: %v", err)
-		}
+	isBroken := func(dir string) bool {
+		return slices.Contains(broken, dir)
+	}
+	themeInfo.Directories = slices.DeleteFunc(themeInfo.Directories, isBroken)
+	themeInfo.ScaledDirectories = slices.DeleteFunc(themeInfo.ScaledDirectories, isBroken)
 
-		subDirIconInfo := SubDirIconInfo{
-			Size: size,
-		}
+	themeInfo.Warnings = warnings
+	return themeInfo, nil
+}
 
-		scaleKey, err := dirSection.GetKey("Scale")
-		if err != nil {
-			subDirIconInfo.Scale = 1
-		} else {
-			subDirIconInfo.Scale = scaleKey.MustInt(1)
-		}
+// parses the section describing dir
+func readDirSection(index *ini.File, dir string, warnings *[]string) (SubDirIconInfo, error) {
+	dirSection, err := findDirSection(index, dir)
+	if err != nil {
+		return SubDirIconInfo{}, fmt.Errorf("section is missing")
+	}
 
-		typeKey, err := dirSection.GetKey("Type")
-		if err != nil {
-			subDirIconInfo.Type = "Threshold"
-		} else {
-			subDirIconInfo.Type = typeKey.MustString("Threshold")
-		}
+	sizeValue, err := dirSection.GetKey("Size")
+	if err != nil {
+		return SubDirIconInfo{}, fmt.Errorf("required key Size is missing")
+	}
 
-		maxSizeKey, err := dirSection.GetKey("MaxSize")
-		if err != nil {
-			subDirIconInfo.MaxSize = subDirIconInfo.Size
-		} else {
-			subDirIconInfo.MaxSize = maxSizeKey.MustInt(subDirIconInfo.Size)
-		}
+	size, quirk, err := parseSize(sizeValue.String())
+	if err != nil {
+		return SubDirIconInfo{}, fmt.Errorf("invalid Size: %v", err)
+	}
+	if quirk {
+		*warnings = append(*warnings, fmt.Sprintf("[%s] Size=%s is not a plain number", dir, sizeValue.String()))
+	}
 
-		minSizeKey, err := dirSection.GetKey("MinSize")
-		if err != nil {
-			subDirIconInfo.MinSize = subDirIconInfo.Size
-		} else {
-			subDirIconInfo.MinSize = minSizeKey.MustInt(subDirIconInfo.Size)
-		}
+	subDirIconInfo := SubDirIconInfo{
+		Size: size,
+	}
 
-		thresholdKey, err := dirSection.GetKey("Threshold")
-		if err != nil {
-			subDirIconInfo.Threshold = 2
-		} else {
-			subDirIconInfo.Threshold = thresholdKey.MustInt(2)
-		}
+	scaleKey, err := dirSection.GetKey("Scale")
+	if err != nil {
+		subDirIconInfo.Scale = 1
+	} else {
+		subDirIconInfo.Scale = scaleKey.MustInt(1)
+	}
 
-		themeInfo.directoryMap[dir] = subDirIconInfo
+	typeKey, err := dirSection.GetKey("Type")
+	if err != nil {
+		subDirIconInfo.Type = "Threshold"
+	} else {
+		subDirIconInfo.Type = typeKey.MustString("Threshold")
 	}
 
-	return themeInfo, nil
+	subDirIconInfo.MaxSize = sizeKey(dirSection, dir, "MaxSize", subDirIconInfo.Size, warnings)
+	subDirIconInfo.MinSize = sizeKey(dirSection, dir, "MinSize", subDirIconInfo.Size, warnings)
+
+	thresholdKey, err := dirSection.GetKey("Threshold")
+	if err != nil {
+		subDirIconInfo.Threshold = 2
+	} else {
+		subDirIconInfo.Threshold = thresholdKey.MustInt(2)
+	}
+
+	if contextKey, err := dirSection.GetKey("Context"); err == nil {
+		subDirIconInfo.Context = contextKey.String()
+	}
+
+	return subDirIconInfo, nil
 }
@@ -0,0 +1,79 @@
+package xdgicons
+
+import "slices"
+
+// A theme in the inheritance tree returned by [IconLookup.ThemeTree]
+type ThemeNode struct {
+	// Name of the theme's directory
+	Theme string
+
+	// Parsed index.theme of the theme, if it's installed
+	Info ThemeInfo
+
+	// Whether the theme is installed. Themes that aren't
+	// are skipped by lookups and have no parents.
+	Installed bool
+
+	// Position of the theme in the search order, starting at 0.
+	// -1 if it isn't searched at this point of the tree, because
+	// it isn't installed, is a Duplicate or closes a Cycle.
+	Order int
+
+	// Whether the theme was reached before through another path,
+	// so it's searched there along with its parents, which
+	// aren't listed here again
+	Duplicate bool
+
+	// Whether the theme is also one of its own descendants in the
+	// tree, i.e. it inherits from itself through its parents. Such
+	// cycles are cut and the theme's parents aren't listed here again.
+	Cycle bool
+
+	// Themes the theme inherits from, in order, including the ones
+	// added implicitly, like hicolor (see [ThemeInfo.Inherits])
+	Parents []*ThemeNode
+}
+
+// Returns the inheritance tree of theme, as searched by lookups:
+// depth first, each theme once, parents in the order they are
+// inherited, with hicolor and [LookupConfig.ImplicitInherits] added.
+// Meant for debugging which themes are searched and in what order.
+//
+// Returns a [ThemeNotFoundError] if theme isn't installed.
+func (il *IconLookup) ThemeTree(theme string) (*ThemeNode, error) {
+	if _, err := il.readThemeInfo(theme); err != nil {
+		return nil, err
+	}
+
+	order := 0
+	searched := make(map[string]bool)
+	var build func(theme string, ancestors []string) *ThemeNode
+	build = func(theme string, ancestors []string) *ThemeNode {
+		node := &ThemeNode{Theme: theme, Order: -1}
+		if slices.Contains(ancestors, theme) {
+			node.Cycle = true
+			return node
+		}
+		if searched[theme] {
+			node.Duplicate = true
+			return node
+		}
+
+		themeInfo, err := il.readThemeInfo(theme)
+		if err != nil {
+			return node
+		}
+		node.Info = themeInfo
+		node.Installed = true
+		node.Order = order
+		order++
+		searched[theme] = true
+
+		ancestors = append(ancestors, theme)
+		for _, parent := range themeInfo.Inherits {
+			node.Parents = append(node.Parents, build(parent, ancestors))
+		}
+		return node
+	}
+	return build(theme, nil), nil
+}
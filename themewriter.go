@@ -0,0 +1,141 @@
+package xdgicons
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Describes subdir in the index.theme written by [WriteThemeIndex].
+// subdir should be listed in Directories or ScaledDirectories.
+func (t *ThemeInfo) SetDirectory(subdir string, info SubDirIconInfo) {
+	if t.directoryMap == nil {
+		t.directoryMap = make(map[string]SubDirIconInfo)
+	}
+	t.directoryMap[subdir] = info
+}
+
+// Writes themeInfo as an index.theme to w, e.g. for tools assembling
+// icon themes. Every directory in Directories and ScaledDirectories
+// needs to be described, either by reading the theme or with
+// [ThemeInfo.SetDirectory]. Keys with default values are left out, as
+// are the themes added to Inherits of read themes, like hicolor.
+//
+// Returns an error if a required key would be empty, a directory
+// isn't described, or a value can't be written, e.g. a list entry
+// containing a comma.
+func WriteThemeIndex(themeInfo ThemeInfo, w io.Writer) error {
+	if themeInfo.Name == "" {
+		return fmt.Errorf("error writing theme index: Name is empty")
+	}
+	if len(themeInfo.Directories) == 0 {
+		return fmt.Errorf("error writing theme index: Directories is empty")
+	}
+
+	var buf bytes.Buffer
+	var err error
+	writeKey := func(key, value string) {
+		if err == nil && strings.ContainsAny(value, "\n\r") {
+			err = fmt.Errorf("error writing theme index: %s contains a line break", key)
+		}
+		fmt.Fprintf(&buf, "%s=%s\n", key, value)
+	}
+	writeList := func(key string, values []string) {
+		for _, value := range values {
+			if err == nil && (value == "" || strings.Contains(value, ",")) {
+				err = fmt.Errorf("error writing theme index: %s has invalid entry %q", key, value)
+			}
+		}
+		writeKey(key, strings.Join(values, ","))
+	}
+
+	// the untranslated values and translations of read themes, unless
+	// changed since, so the current locale isn't baked in
+	read := themeInfo.localized
+	keepName := read != nil && themeInfo.Name == read.name
+	keepComment := read != nil && themeInfo.Comment == read.localizedComment
+
+	buf.WriteString("[Icon Theme]\n")
+	writeKey("Name", themeInfo.Name)
+	// required by the spec
+	comment := themeInfo.Comment
+	if keepComment {
+		comment = read.comment
+	}
+	if comment == "" {
+		comment = themeInfo.Name
+	}
+	writeKey("Comment", comment)
+	if read != nil {
+		for _, key := range slices.Sorted(maps.Keys(read.translations)) {
+			if strings.HasPrefix(key, "Name[") && keepName || strings.HasPrefix(key, "Comment[") && keepComment {
+				writeKey(key, read.translations[key])
+			}
+		}
+	}
+	// only the themes the read index.theme listed
+	inherits := slices.DeleteFunc(slices.Clone(themeInfo.Inherits), func(parent string) bool {
+		return slices.Contains(themeInfo.implicitInherits, parent)
+	})
+	if len(inherits) > 0 {
+		writeList("Inherits", inherits)
+	}
+	writeList("Directories", themeInfo.Directories)
+	if len(themeInfo.ScaledDirectories) > 0 {
+		writeList("ScaledDirectories", themeInfo.ScaledDirectories)
+	}
+	if themeInfo.Hidden {
+		writeKey("Hidden", "true")
+	}
+	if themeInfo.Example != "" {
+		writeKey("Example", themeInfo.Example)
+	}
+
+	for _, dir := range themeInfo.allDirectories() {
+		info, ok := themeInfo.directoryMap[dir]
+		if !ok {
+			return fmt.Errorf("error writing theme index: directory %q isn't described", dir)
+		}
+		if strings.ContainsAny(dir, "[]") {
+			return fmt.Errorf("error writing theme index: invalid directory %q", dir)
+		}
+
+		fmt.Fprintf(&buf, "\n[%s]\n", dir)
+		writeKey("Size", strconv.Itoa(info.Size))
+		if info.Scale > 1 {
+			writeKey("Scale", strconv.Itoa(info.Scale))
+		}
+		if info.Context != "" {
+			writeKey("Context", info.Context)
+		}
+
+		dirType := info.Type
+		if dirType == "" {
+			dirType = "Threshold"
+		}
+		writeKey("Type", dirType)
+		switch dirType {
+		case "Scalable":
+			if info.MinSize != 0 && info.MinSize != info.Size {
+				writeKey("MinSize", strconv.Itoa(info.MinSize))
+			}
+			if info.MaxSize != 0 && info.MaxSize != info.Size {
+				writeKey("MaxSize", strconv.Itoa(info.MaxSize))
+			}
+		case "Threshold":
+			if info.Threshold != 0 && info.Threshold != 2 {
+				writeKey("Threshold", strconv.Itoa(info.Threshold))
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,53 @@
+package xdgicons_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/codelif/xdgicons"
+	"github.com/codelif/xdgicons/testutil"
+)
+
+// Writing a theme that was read must not bake in the current
+// locale or the themes added to Inherits
+func TestWriteThemeIndexRoundTrip(t *testing.T) {
+	testutil.SetupEnv(t, testutil.Theme{
+		Name: "Translated",
+		RawIndex: `[Icon Theme]
+Name=Translated
+Name[de]=Übersetzt
+Comment=A theme
+Comment[de]=Ein Thema
+Directories=48x48/apps
+
+[48x48/apps]
+Size=48
+`,
+		Dirs: []testutil.Dir{{Path: "48x48/apps", Size: 48}},
+	}, testutil.Hicolor())
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+
+	il := xdgicons.NewIconLookupWithConfig(xdgicons.LookupConfig{Theme: "Translated"})
+	themeInfo, err := il.ThemeInfo("Translated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if themeInfo.Comment != "Ein Thema" {
+		t.Fatalf("Comment = %q, want the German one", themeInfo.Comment)
+	}
+
+	var buf bytes.Buffer
+	if err := xdgicons.WriteThemeIndex(themeInfo, &buf); err != nil {
+		t.Fatal(err)
+	}
+	index := buf.String()
+	for _, want := range []string{"Name=Translated\n", "Name[de]=Übersetzt\n", "Comment=A theme\n", "Comment[de]=Ein Thema\n"} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index.theme lacks %q:\n%s", want, index)
+		}
+	}
+	if strings.Contains(index, "Inherits") {
+		t.Errorf("index.theme lists the implicit hicolor:\n%s", index)
+	}
+}
@@ -0,0 +1,183 @@
+package xdgicons
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// A violation of the icon theme spec, as found by [ValidateTheme]
+type Problem struct {
+	// Section of index.theme the problem is in, e.g. "Icon Theme"
+	// or a directory. Empty if it isn't about a section.
+	Section string
+
+	// Key the problem is about, empty if it isn't about a key
+	Key string
+
+	// What's wrong
+	Message string
+
+	// Whether the problem is worked around by lookups, or only
+	// matters to other implementations, so the theme works anyway
+	Warning bool
+}
+
+func (p Problem) String() string {
+	var s strings.Builder
+	if p.Warning {
+		s.WriteString("warning: ")
+	} else {
+		s.WriteString("error: ")
+	}
+	if p.Section != "" {
+		fmt.Fprintf(&s, "[%s] ", p.Section)
+	}
+	if p.Key != "" {
+		fmt.Fprintf(&s, "%s: ", p.Key)
+	}
+	s.WriteString(p.Message)
+	return s.String()
+}
+
+// Checks the index.theme of the theme in themeDir against the icon
+// theme spec, e.g. for missing required keys, directories without a
+// section or Size, directories declared but absent on disk, scaled
+// directories listed in Directories and inheritance of themes that
+// aren't installed next to it or in any of the base directories.
+//
+// Returns nil if no problems were found.
+func ValidateTheme(themeDir string) []Problem {
+	var problems []Problem
+	report := func(section, key string, warning bool, format string, args ...any) {
+		problems = append(problems, Problem{
+			Section: section,
+			Key:     key,
+			Message: fmt.Sprintf(format, args...),
+			Warning: warning,
+		})
+	}
+
+	indexPath, warning, ok := findThemeIndex(themeDir)
+	if !ok {
+		report("", "", false, "no index.theme in %s", themeDir)
+		return problems
+	}
+	if warning != "" {
+		report("", "", true, "%s", warning)
+	}
+
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		report("", "", false, "error reading file: %v", err)
+		return problems
+	}
+	index, err := ini.Load(data)
+	if err != nil {
+		report("", "", false, "error parsing file: %v", err)
+		return problems
+	}
+	for _, section := range duplicateSections(data) {
+		report(section, "", true, "section appears more than once")
+	}
+
+	iconThemeSection, err := index.GetSection("Icon Theme")
+	if err != nil {
+		report("Icon Theme", "", false, "required section is missing")
+		return problems
+	}
+	for _, name := range []string{"Name", "Comment", "Directories"} {
+		if !iconThemeSection.HasKey(name) {
+			report("Icon Theme", name, false, "required key is missing")
+		}
+	}
+
+	if inheritsKey, err := iconThemeSection.GetKey("Inherits"); err == nil {
+		baseDirs := append([]string{path.Dir(path.Clean(themeDir))}, GetBaseDirs()...)
+		for _, parent := range inheritsKey.Strings(",") {
+			installed := slices.ContainsFunc(baseDirs, func(baseDir string) bool {
+				_, _, ok := findThemeIndex(path.Join(baseDir, parent))
+				return ok
+			})
+			if !installed {
+				report("Icon Theme", "Inherits", false, "inherited theme %q isn't installed", parent)
+			}
+		}
+	}
+
+	var warnings []string
+	var directories, scaledDirectories []string
+	if key, err := iconThemeSection.GetKey("Directories"); err == nil {
+		directories = normalizeDirList(key, &warnings)
+	}
+	if key, err := iconThemeSection.GetKey("ScaledDirectories"); err == nil {
+		scaledDirectories = normalizeDirList(key, &warnings)
+	}
+	for _, warning := range warnings {
+		report("Icon Theme", "", true, "%s", warning)
+	}
+
+	seen := make(map[string]bool)
+	for i, dir := range slices.Concat(directories, scaledDirectories) {
+		if seen[dir] {
+			report("Icon Theme", "", true, "directory %q is listed more than once", dir)
+			continue
+		}
+		seen[dir] = true
+
+		if stat, err := os.Stat(path.Join(themeDir, dir)); err != nil || !stat.IsDir() {
+			report(dir, "", true, "directory doesn't exist")
+		}
+
+		dirSection, err := findDirSection(index, dir)
+		if err != nil {
+			report(dir, "", false, "section of listed directory is missing")
+			continue
+		}
+
+		if sizeValue, err := dirSection.GetKey("Size"); err != nil {
+			report(dir, "Size", false, "required key is missing")
+		} else if _, quirk, err := parseSize(sizeValue.String()); err != nil {
+			report(dir, "Size", false, "%v", err)
+		} else if quirk {
+			report(dir, "Size", true, "%q is not a plain number", sizeValue.String())
+		}
+
+		if scaleKey, err := dirSection.GetKey("Scale"); err == nil {
+			scale, err := strconv.Atoi(strings.TrimSpace(scaleKey.String()))
+			if err != nil || scale < 1 {
+				report(dir, "Scale", false, "invalid scale %q", scaleKey.String())
+			} else if scale != 1 && i < len(directories) {
+				report(dir, "Scale", true, "scaled directory is listed in Directories instead of ScaledDirectories")
+			}
+		}
+
+		dirType := "Threshold"
+		if typeKey, err := dirSection.GetKey("Type"); err == nil {
+			dirType = typeKey.String()
+			if !slices.Contains([]string{"Fixed", "Scalable", "Threshold"}, dirType) {
+				report(dir, "Type", false, "invalid type %q", dirType)
+			}
+		}
+
+		for _, name := range []string{"MinSize", "MaxSize", "Threshold"} {
+			key, err := dirSection.GetKey(name)
+			if err != nil {
+				continue
+			}
+			if _, _, err := parseSize(key.String()); err != nil {
+				report(dir, name, false, "%v", err)
+			}
+			if (name == "Threshold" && dirType != "Threshold") || (name != "Threshold" && dirType != "Scalable") {
+				report(dir, name, true, "key is ignored for Type=%s", dirType)
+			}
+		}
+	}
+
+	return problems
+}
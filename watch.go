@@ -0,0 +1,170 @@
+package xdgicons
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type dirWatcher struct {
+	sub  *watchSubscription
+	done chan struct{}
+
+	mu      sync.Mutex
+	pending map[string]*time.Timer
+}
+
+// Watches every base directory (or its parent, if it doesn't exist yet)
+// and the theme directories in it, and rescans a base directory when
+// something in them changes. Directories below the theme directories
+// aren't watched, their changes are found by polling mtimes.
+//
+// The watches are shared with other instances through [sharedWatchHub].
+func (il *IconLookup) startWatching() error {
+	dw := &dirWatcher{
+		done:    make(chan struct{}),
+		pending: make(map[string]*time.Timer),
+	}
+
+	sub, err := sharedWatchHub.subscribe(func(event fsnotify.Event) {
+		il.handleWatchEvent(dw, event)
+	}, func() {
+		// events were lost, so anything may have changed
+		for _, baseDir := range il.getBaseDirs() {
+			il.scheduleRescan(dw, baseDir, il.rescanDebounce+il.randomJitter())
+		}
+	})
+	if err != nil {
+		return err
+	}
+	dw.sub = sub
+
+	for _, baseDir := range il.getBaseDirs() {
+		il.watchBaseDir(dw, baseDir)
+	}
+
+	il.watcher = dw
+	return nil
+}
+
+func (il *IconLookup) watchBaseDir(dw *dirWatcher, baseDir string) {
+	// listing it would hang as well
+	if il.degraded(baseDir) {
+		return
+	}
+	if err := dw.sub.add(baseDir); err != nil {
+		// wait for the base directory to be created
+		_ = dw.sub.add(filepath.Dir(baseDir))
+		return
+	}
+
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if isDirEntry(baseDir, entry) {
+			// most likely out of inotify watches otherwise,
+			// which leaves the theme to polling
+			_ = dw.sub.add(filepath.Join(baseDir, entry.Name()))
+		}
+	}
+}
+
+func (il *IconLookup) handleWatchEvent(dw *dirWatcher, event fsnotify.Event) {
+	for _, baseDir := range il.getBaseDirs() {
+		if event.Name != baseDir && filepath.Dir(event.Name) != baseDir && filepath.Dir(filepath.Dir(event.Name)) != baseDir {
+			continue
+		}
+
+		if event.Has(fsnotify.Create) {
+			if event.Name == baseDir {
+				il.watchBaseDir(dw, baseDir)
+			} else if filepath.Dir(event.Name) == baseDir {
+				// a new theme directory
+				if stat, err := os.Stat(event.Name); err == nil && stat.IsDir() {
+					_ = dw.sub.add(event.Name)
+				}
+			}
+		}
+
+		il.scheduleRescan(dw, baseDir, il.rescanDebounce+il.randomJitter())
+	}
+}
+
+// Rescans baseDir after delay, restarting the wait if another rescan
+// is already pending (debouncing), and postponing it further if the
+// directory was scanned less than the minimum rescan interval ago.
+func (il *IconLookup) scheduleRescan(dw *dirWatcher, baseDir string, delay time.Duration) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	if timer, ok := dw.pending[baseDir]; ok {
+		timer.Reset(delay)
+		return
+	}
+
+	dw.pending[baseDir] = time.AfterFunc(delay, func() {
+		dw.mu.Lock()
+		delete(dw.pending, baseDir)
+		dw.mu.Unlock()
+
+		select {
+		case <-dw.done:
+			return
+		default:
+		}
+
+		il.mu.RLock()
+		cacheEntry := il.dirCache[baseDir]
+		il.mu.RUnlock()
+
+		if cacheEntry != nil {
+			if wait := il.minRescanInterval - time.Since(cacheEntry.lastScan); wait > 0 {
+				il.scheduleRescan(dw, baseDir, wait+il.randomJitter())
+				return
+			}
+		}
+
+		_ = il.cacheBaseDirectory(context.Background(), baseDir)
+
+		// themes that were missing so far may have been installed
+		il.mu.Lock()
+		il.missingThemes = make(map[string]bool)
+		il.mu.Unlock()
+	})
+}
+
+// Stops watching the base directories and the background refresher,
+// if [LookupConfig.Watch] or [LookupConfig.BackgroundRefresh] was set.
+// Lookups go back to revalidating the base directories themselves.
+// Also stops following the system theme, see [LookupConfig.XSettings]
+// and [LookupConfig.FollowSystemTheme].
+func (il *IconLookup) Close() error {
+	il.stopRefresher()
+	il.stopXSettings()
+	il.stopFollowingTheme()
+
+	il.mu.Lock()
+	dw := il.watcher
+	il.watcher = nil
+	il.mu.Unlock()
+
+	if dw == nil {
+		return nil
+	}
+
+	close(dw.done)
+
+	dw.mu.Lock()
+	for _, timer := range dw.pending {
+		timer.Stop()
+	}
+	dw.mu.Unlock()
+
+	return dw.sub.close()
+}
@@ -0,0 +1,210 @@
+package xdgicons
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Process-wide fsnotify watcher shared by every IconLookup, so each
+// directory is watched once no matter how many instances exist.
+// Events are fanned out to every subscription watching the directory
+// they happened in, and handled on a goroutine of the subscription, so
+// a slow handler (e.g. one listing a new theme) doesn't hold up the
+// others or the kernel's event queue.
+type watchHub struct {
+	mu            sync.Mutex
+	watcher       *fsnotify.Watcher
+	refs          map[string]int
+	subscriptions map[*watchSubscription]struct{}
+}
+
+type watchSubscription struct {
+	hub     *watchHub
+	paths   map[string]bool
+	handler func(fsnotify.Event)
+
+	// called when events were dropped by the kernel
+	overflow func()
+
+	mu sync.Mutex
+	// calls of handler and overflow not made yet, in order
+	queue   []func()
+	running bool
+	closed  bool
+}
+
+var sharedWatchHub = &watchHub{}
+
+func (h *watchHub) subscribe(handler func(fsnotify.Event), overflow func()) (*watchSubscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.watcher == nil {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return nil, err
+		}
+		h.watcher = watcher
+		h.refs = make(map[string]int)
+		h.subscriptions = make(map[*watchSubscription]struct{})
+		go h.dispatch(watcher)
+	}
+
+	sub := &watchSubscription{
+		hub:      h,
+		paths:    make(map[string]bool),
+		handler:  handler,
+		overflow: overflow,
+	}
+	h.subscriptions[sub] = struct{}{}
+	return sub, nil
+}
+
+func (h *watchHub) dispatch(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			h.mu.Lock()
+			var targets []*watchSubscription
+			for sub := range h.subscriptions {
+				if sub.paths[event.Name] || sub.paths[filepath.Dir(event.Name)] {
+					targets = append(targets, sub)
+				}
+			}
+			if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				h.forget(event.Name)
+			}
+			h.mu.Unlock()
+
+			for _, sub := range targets {
+				sub.post(func() { sub.handler(event) })
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			if !errors.Is(err, fsnotify.ErrEventOverflow) {
+				continue
+			}
+
+			h.mu.Lock()
+			var targets []*watchSubscription
+			for sub := range h.subscriptions {
+				targets = append(targets, sub)
+			}
+			h.mu.Unlock()
+
+			for _, sub := range targets {
+				sub.post(sub.overflow)
+			}
+		}
+	}
+}
+
+// Queues fn to be called on the goroutine of s, which is
+// started if it isn't running
+func (s *watchSubscription) post(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.queue = append(s.queue, fn)
+	if !s.running {
+		s.running = true
+		go s.deliver()
+	}
+}
+
+// handlers may add watches, so they run without any lock held
+func (s *watchSubscription) deliver() {
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 || s.closed {
+			s.running = false
+			s.mu.Unlock()
+			return
+		}
+		fn := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		fn()
+	}
+}
+
+// Drops the watch of a removed or renamed directory, so it is watched
+// again if it is recreated. Must be called with h.mu held.
+func (h *watchHub) forget(dirPath string) {
+	if h.refs[dirPath] == 0 {
+		return
+	}
+	delete(h.refs, dirPath)
+	_ = h.watcher.Remove(dirPath)
+	for sub := range h.subscriptions {
+		delete(sub.paths, dirPath)
+	}
+}
+
+// starts watching dirPath for this subscription
+func (s *watchSubscription) add(dirPath string) error {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s.paths == nil || s.paths[dirPath] {
+		return nil
+	}
+
+	if h.refs[dirPath] == 0 {
+		if err := h.watcher.Add(dirPath); err != nil {
+			return err
+		}
+	}
+	h.refs[dirPath]++
+	s.paths[dirPath] = true
+	return nil
+}
+
+// stops all watches of this subscription, closing the shared
+// watcher once nobody is subscribed anymore
+func (s *watchSubscription) close() error {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s.paths == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.closed = true
+	s.queue = nil
+	s.mu.Unlock()
+
+	for dirPath := range s.paths {
+		h.refs[dirPath]--
+		if h.refs[dirPath] <= 0 {
+			delete(h.refs, dirPath)
+			_ = h.watcher.Remove(dirPath)
+		}
+	}
+	s.paths = nil
+	delete(h.subscriptions, s)
+
+	if len(h.subscriptions) > 0 {
+		return nil
+	}
+
+	err := h.watcher.Close()
+	h.watcher = nil
+	return err
+}
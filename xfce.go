@@ -0,0 +1,60 @@
+package xdgicons
+
+import (
+	"encoding/xml"
+	"os"
+	"path"
+)
+
+// a channel file of xfconf, e.g. xsettings.xml
+type xfconfChannel struct {
+	Properties []xfconfProperty `xml:"property"`
+}
+
+type xfconfProperty struct {
+	Name       string           `xml:"name,attr"`
+	Value      string           `xml:"value,attr"`
+	Properties []xfconfProperty `xml:"property"`
+}
+
+// Reads Net/IconThemeName from the xsettings channel of xfconf,
+// the user's first and the system-wide ones after. Returns "" if
+// none sets it.
+func xfceIconTheme() string {
+	for _, configDir := range listConfigDirs() {
+		data, err := os.ReadFile(path.Join(configDir, "xfce4", "xfconf", "xfce-perchannel-xml", "xsettings.xml"))
+		if err != nil {
+			continue
+		}
+		var channel xfconfChannel
+		if err := xml.Unmarshal(data, &channel); err != nil {
+			continue
+		}
+		if theme := channel.lookup("Net", "IconThemeName"); theme != "" {
+			return theme
+		}
+	}
+	return ""
+}
+
+// returns the value of the property at the given path
+func (c xfconfChannel) lookup(names ...string) string {
+	properties := c.Properties
+	for i, name := range names {
+		found := false
+		for _, property := range properties {
+			if property.Name != name {
+				continue
+			}
+			if i == len(names)-1 {
+				return property.Value
+			}
+			properties, found = property.Properties, true
+			break
+		}
+		if !found {
+			return ""
+		}
+	}
+	return ""
+}
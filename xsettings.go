@@ -0,0 +1,275 @@
+package xdgicons
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// the XSETTINGS setting holding the icon theme
+const xsettingsIconThemeKey = "Net/IconThemeName"
+
+// upper bound of the settings property read, in 32-bit units
+const xsettingsMaxLength = 1 << 18
+
+// Client of the XSETTINGS manager of an X display (e.g. xsettingsd or
+// the settings daemon of a desktop), which broadcasts the theme to GTK2
+// and other X11 toolkits through a property of its selection owner.
+type xsettingsClient struct {
+	conn      *xgb.Conn
+	root      xproto.Window
+	selection xproto.Atom
+	settings  xproto.Atom
+	manager   xproto.Atom
+	done      chan struct{}
+
+	// only touched by the event loop once it's running
+	owner xproto.Window
+
+	mu    sync.Mutex
+	theme string
+}
+
+// Connects to the display named by DISPLAY and reads the icon theme
+// of its XSETTINGS manager, if one is running. Changes are only
+// picked up once [IconLookup.watchXSettings] runs.
+func connectXSettings() (*xsettingsClient, error) {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to X display: %v", err)
+	}
+
+	roots := xproto.Setup(conn).Roots
+	if conn.DefaultScreen >= len(roots) {
+		conn.Close()
+		return nil, fmt.Errorf("error connecting to X display: no screen %d", conn.DefaultScreen)
+	}
+
+	xs := &xsettingsClient{
+		conn: conn,
+		root: roots[conn.DefaultScreen].Root,
+		done: make(chan struct{}),
+	}
+
+	for name, atom := range map[string]*xproto.Atom{
+		"_XSETTINGS_S" + strconv.Itoa(conn.DefaultScreen): &xs.selection,
+		"_XSETTINGS_SETTINGS":                             &xs.settings,
+		"MANAGER":                                         &xs.manager,
+	} {
+		reply, err := xproto.InternAtom(conn, false, uint16(len(name)), name).Reply()
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error interning atom %s: %v", name, err)
+		}
+		*atom = reply.Atom
+	}
+
+	// managers announce themselves with a MANAGER client message to
+	// the root window, which is only delivered with StructureNotify
+	err = xproto.ChangeWindowAttributesChecked(conn, xs.root, xproto.CwEventMask,
+		[]uint32{xproto.EventMaskStructureNotify}).Check()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error selecting root window events: %v", err)
+	}
+
+	xs.findOwner()
+	xs.theme = xs.readIconTheme()
+	return xs, nil
+}
+
+// returns the last icon theme broadcast by the manager
+func (xs *xsettingsClient) iconTheme() string {
+	xs.mu.Lock()
+	defer xs.mu.Unlock()
+	return xs.theme
+}
+
+// finds the current manager and asks to be told when its
+// settings change or it goes away
+func (xs *xsettingsClient) findOwner() {
+	xs.owner = xproto.WindowNone
+
+	reply, err := xproto.GetSelectionOwner(xs.conn, xs.selection).Reply()
+	if err != nil || reply.Owner == xproto.WindowNone {
+		return
+	}
+
+	// fails if the manager exited in the meantime
+	err = xproto.ChangeWindowAttributesChecked(xs.conn, reply.Owner, xproto.CwEventMask,
+		[]uint32{xproto.EventMaskPropertyChange | xproto.EventMaskStructureNotify}).Check()
+	if err != nil {
+		return
+	}
+	xs.owner = reply.Owner
+}
+
+// reads the icon theme from the manager's settings,
+// "" if there's no manager or it isn't set
+func (xs *xsettingsClient) readIconTheme() string {
+	if xs.owner == xproto.WindowNone {
+		return ""
+	}
+
+	reply, err := xproto.GetProperty(xs.conn, false, xs.owner, xs.settings,
+		xproto.GetPropertyTypeAny, 0, xsettingsMaxLength).Reply()
+	if err != nil {
+		return ""
+	}
+	theme, _ := xsettingsString(reply.Value, xsettingsIconThemeKey)
+	return theme
+}
+
+// detects the theme to use if none was configured,
+// preferring the one broadcast over XSETTINGS
+func (il *IconLookup) detectTheme() string {
+	il.mu.RLock()
+	xs := il.xsettings
+	il.mu.RUnlock()
+
+	if xs != nil {
+		if theme := xs.iconTheme(); theme != "" {
+			return theme
+		}
+	}
+	return DefaultTheme()
+}
+
+// Follows the manager's settings until the connection is closed,
+// switching the theme every time the broadcast icon theme changes.
+func (il *IconLookup) watchXSettings(xs *xsettingsClient) {
+	defer close(xs.done)
+
+	for {
+		event, err := xs.conn.WaitForEvent()
+		if event == nil && err == nil {
+			// the connection was closed
+			return
+		}
+
+		switch event := event.(type) {
+		case xproto.PropertyNotifyEvent:
+			if event.Window != xs.owner || event.Atom != xs.settings {
+				continue
+			}
+		case xproto.DestroyNotifyEvent:
+			// keep the last theme until another manager shows up
+			if event.Window == xs.owner {
+				xs.findOwner()
+			}
+			continue
+		case xproto.ClientMessageEvent:
+			if event.Type != xs.manager || event.Data.Data32[1] != uint32(xs.selection) {
+				continue
+			}
+			xs.findOwner()
+		default:
+			continue
+		}
+
+		theme := xs.readIconTheme()
+		if theme == "" {
+			continue
+		}
+		xs.mu.Lock()
+		xs.theme = theme
+		xs.mu.Unlock()
+
+		il.switchTheme(theme)
+	}
+}
+
+// closes the XSETTINGS connection, if open, and waits for
+// the event loop to exit
+func (il *IconLookup) stopXSettings() {
+	il.mu.Lock()
+	xs := il.xsettings
+	il.xsettings = nil
+	il.mu.Unlock()
+
+	if xs == nil {
+		return
+	}
+	xs.conn.Close()
+	<-xs.done
+}
+
+// Returns the value of the string setting name from the contents of
+// an _XSETTINGS_SETTINGS property, false if it isn't set or the data
+// is malformed.
+//
+// The format starts with a byte order, padding, a serial and the number
+// of settings. Each setting has a type, padding, the length of its name,
+// the name padded to 4 bytes, a serial, and its value: a CARD32 for
+// integers, a length and the data padded to 4 bytes for strings, and
+// four CARD16 for colors.
+func xsettingsString(data []byte, name string) (string, bool) {
+	if len(data) < 12 {
+		return "", false
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	switch data[0] {
+	case 0: // LSBFirst
+	case 1: // MSBFirst
+		order = binary.BigEndian
+	default:
+		return "", false
+	}
+
+	count := order.Uint32(data[8:])
+	data = data[12:]
+	for ; count > 0; count-- {
+		if len(data) < 4 {
+			return "", false
+		}
+		settingType := data[0]
+		nameLength := int(order.Uint16(data[2:]))
+		data = data[4:]
+
+		paddedLength := xsettingsPad(nameLength) + 4
+		if len(data) < paddedLength {
+			return "", false
+		}
+		settingName := string(data[:nameLength])
+		data = data[paddedLength:]
+
+		switch settingType {
+		case 0: // integer
+			if len(data) < 4 {
+				return "", false
+			}
+			data = data[4:]
+		case 1: // string
+			if len(data) < 4 {
+				return "", false
+			}
+			valueLength := order.Uint32(data)
+			data = data[4:]
+			if uint64(valueLength) > uint64(len(data)) || xsettingsPad(int(valueLength)) > len(data) {
+				return "", false
+			}
+			if settingName == name {
+				return string(data[:valueLength]), true
+			}
+			data = data[xsettingsPad(int(valueLength)):]
+		case 2: // color
+			if len(data) < 8 {
+				return "", false
+			}
+			data = data[8:]
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// rounds n up to a multiple of 4
+func xsettingsPad(n int) int {
+	return (n + 3) &^ 3
+}